@@ -0,0 +1,42 @@
+module github.com/asimpleidea/appoint/api/appointments
+
+go 1.18
+
+require (
+	github.com/asimpleidea/appoint/api/core v0.0.0-00010101000000-000000000000
+	github.com/asimpleidea/appoint/api/services v0.0.0-00010101000000-000000000000
+	github.com/asimpleidea/appoint/api/timetables v0.0.0-00010101000000-000000000000
+	github.com/gofiber/fiber/v2 v2.35.0
+	github.com/jackc/pgconn v1.12.1
+	github.com/rs/zerolog v1.27.0
+	gorm.io/driver/postgres v1.3.8
+	gorm.io/gorm v1.23.8
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.4 // indirect
+	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
+	github.com/jackc/pgio v1.0.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgproto3/v2 v2.3.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20200714003250-2b9c44734f2b // indirect
+	github.com/jackc/pgtype v1.11.0 // indirect
+	github.com/jackc/pgx/v4 v4.16.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.4 // indirect
+	github.com/klauspost/compress v1.15.0 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasthttp v1.38.0 // indirect
+	github.com/valyala/tcplisten v1.0.0 // indirect
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292 // indirect
+	golang.org/x/sys v0.0.0-20220227234510-4e6760a101f9 // indirect
+	golang.org/x/text v0.3.7 // indirect
+)
+
+replace (
+	github.com/asimpleidea/appoint/api/core => ../core
+	github.com/asimpleidea/appoint/api/services => ../services
+	github.com/asimpleidea/appoint/api/timetables => ../timetables
+)
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+// ChainSegment is the booking of a service of a chain, with the buffers of
+// the service and the status the booking starts with.
+type ChainSegment struct {
+	ServiceID    uint
+	Start        time.Time
+	End          time.Time
+	BufferBefore time.Duration
+	BufferAfter  time.Duration
+	Status       types.BookingStatus
+}
+
+// GetChainByID returns the chain with its bookings, in order.
+func (d *Database) GetChainByID(id uint) (*types.Chain, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	var chain Chain
+	if err := d.DB.Model(&Chain{}).Where("id = ?", id).First(&chain).Error; err != nil {
+		return nil, err
+	}
+
+	bookings := []Booking{}
+	if err := d.DB.Order("starts_at asc").Model(&Booking{}).
+		Where("chain_id = ?", id).Scopes(booked()).Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	converted := chain.ToAPI()
+	converted.Bookings = make([]types.Booking, len(bookings))
+	for i := 0; i < len(bookings); i++ {
+		converted.Bookings[i] = *bookings[i].ToAPI()
+	}
+
+	if len(bookings) > 0 {
+		converted.Start = bookings[0].StartsAt
+		converted.End = bookings[len(bookings)-1].EndsAt
+	}
+
+	return converted, nil
+}
+
+// CreateChain books all the segments of the chain for its customer. Either
+// all of them are booked or none is: if the time of one is taken, a
+// *ConflictError is returned.
+func (d *Database) CreateChain(chain *types.Chain, segments []ChainSegment) (*types.Chain, error) {
+	if chain == nil {
+		return nil, fmt.Errorf("no chain provided")
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no segments provided")
+	}
+
+	bookings := make([]*Booking, len(segments))
+	for i, segment := range segments {
+		booking, err := checkBookingBeforePut(&types.Booking{
+			ServiceID: segment.ServiceID,
+			Start:     segment.Start,
+			End:       segment.End,
+			Customer:  chain.Customer,
+			Notes:     chain.Notes,
+			Status:    segment.Status,
+		}, segment.BufferBefore, segment.BufferAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 && booking.StartsAt.Before(bookings[i-1].EndsAt) {
+			return nil, fmt.Errorf("segment %d starts before the previous one ends", i)
+		}
+
+		if err := d.clearExpiredHolds(booking); err != nil {
+			return nil, err
+		}
+
+		bookings[i] = booking
+	}
+
+	chainToCreate := &Chain{
+		Customer: bookings[0].Customer,
+		Notes:    chain.Notes,
+	}
+
+	var failed *Booking
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(chainToCreate).Error; err != nil {
+			return fmt.Errorf("cannot create chain: %w", err)
+		}
+
+		for _, booking := range bookings {
+			booking.ChainID = &chainToCreate.ID
+			if err := tx.Create(booking).Error; err != nil {
+				failed = booking
+				return err
+			}
+
+			if err := d.recordCreation(tx, booking.ID, booking.Status); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		if failed != nil {
+			failed.ID = 0
+			return nil, d.conflictError(err, failed)
+		}
+
+		return nil, err
+	}
+
+	return d.GetChainByID(chainToCreate.ID)
+}
+
+// CancelChain cancels the chain and the provided bookings of it, charging
+// their fees. Either all of them are cancelled or none is. The bookings
+// that are not provided, like the ones that already started, are kept.
+func (d *Database) CancelChain(id uint, cancellations []Cancellation) ([]types.Booking, error) {
+	if _, err := d.GetChainByID(id); err != nil {
+		return nil, err
+	}
+
+	cancelled := make([]types.Booking, len(cancellations))
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		inTx := *d
+		inTx.DB = tx
+
+		for i, cancellation := range cancellations {
+			if err := tx.Model(&Booking{}).Scopes(byBookingID(cancellation.BookingID), booked()).
+				Where("chain_id = ?", id).First(&Booking{}).Error; err != nil {
+				return err
+			}
+
+			booking, err := inTx.CancelBooking(cancellation.BookingID, cancellation.Fee)
+			if err != nil {
+				return err
+			}
+
+			cancelled[i] = *booking
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&Chain{}).Error; err != nil {
+			return fmt.Errorf("cannot delete chain: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSessionFull is returned when joining a session with no seats left.
+	ErrSessionFull = errors.New("the session is full")
+	// ErrAlreadyAttending is returned when a customer joins a session twice.
+	ErrAlreadyAttending = errors.New("the customer already attends the session")
+)
+
+func (d *Database) GetClassSessionByID(id uint) (*types.ClassSession, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	var session ClassSession
+	if err := d.DB.Model(&ClassSession{}).Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return session.ToAPI(), nil
+}
+
+// GetClassSessions returns the sessions that overlap the interval from
+// start to end, sorted by start. If serviceID is 0, the sessions of all the
+// services are returned.
+func (d *Database) GetClassSessions(serviceID uint, start, end time.Time) ([]types.ClassSession, error) {
+	query := d.DB.Order("starts_at asc").Model(&ClassSession{}).
+		Scopes(overlapping(start, end))
+	if serviceID != 0 {
+		query = query.Scopes(byServiceID(serviceID))
+	}
+
+	sessions := []ClassSession{}
+	if err := query.Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.ClassSession, len(sessions))
+	for i := 0; i < len(sessions); i++ {
+		converted[i] = *sessions[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// CreateClassSession creates a session of the service with capacity seats.
+// Sessions of the same service cannot overlap: if they would, an error
+// wrapping ErrConflict is returned.
+func (d *Database) CreateClassSession(session *types.ClassSession, capacity uint) (*types.ClassSession, error) {
+	if session == nil {
+		return nil, fmt.Errorf("no session provided")
+	}
+
+	if session.ServiceID == 0 {
+		return nil, fmt.Errorf("no service provided")
+	}
+
+	if session.Start.IsZero() || !session.End.After(session.Start) {
+		return nil, fmt.Errorf("invalid start or end provided")
+	}
+
+	if capacity == 0 {
+		return nil, fmt.Errorf("invalid capacity provided")
+	}
+
+	sessionToCreate := &ClassSession{
+		ServiceID: session.ServiceID,
+		StartsAt:  session.Start,
+		EndsAt:    session.End,
+		Capacity:  capacity,
+	}
+
+	if err := d.DB.Create(sessionToCreate).Error; err != nil {
+		if !isExclusionViolation(err) {
+			return nil, err
+		}
+
+		var existing ClassSession
+		if findErr := d.DB.Model(&ClassSession{}).
+			Scopes(byServiceID(session.ServiceID), overlapping(session.Start, session.End)).
+			Order("starts_at asc").First(&existing).Error; findErr != nil {
+			return nil, fmt.Errorf("%w: %s", ErrConflict, err)
+		}
+
+		return nil, fmt.Errorf("%w by session %d, from %s to %s", ErrConflict, existing.ID,
+			existing.StartsAt.Format(time.RFC3339), existing.EndsAt.Format(time.RFC3339))
+	}
+
+	return sessionToCreate.ToAPI(), nil
+}
+
+// DeleteClassSession cancels the session with all its attendees.
+func (d *Database) DeleteClassSession(id uint) error {
+	if _, err := d.GetClassSessionByID(id); err != nil {
+		return err
+	}
+
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", id).Delete(&Attendee{}).Error; err != nil {
+			return fmt.Errorf("cannot delete attendees: %w", err)
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&ClassSession{}).Error; err != nil {
+			return fmt.Errorf("cannot delete session: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetAttendees returns the attendees of the session, in the order they
+// joined.
+func (d *Database) GetAttendees(sessionID uint) ([]types.Attendee, error) {
+	if _, err := d.GetClassSessionByID(sessionID); err != nil {
+		return nil, err
+	}
+
+	attendees := []Attendee{}
+	if err := d.DB.Order("id asc").Model(&Attendee{}).
+		Where("session_id = ?", sessionID).Find(&attendees).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.Attendee, len(attendees))
+	for i := 0; i < len(attendees); i++ {
+		converted[i] = *attendees[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// AddAttendee takes a seat of the session for the attendee. If there is
+// none left, ErrSessionFull is returned.
+func (d *Database) AddAttendee(sessionID uint, attendee *types.Attendee) (*types.Attendee, error) {
+	if attendee == nil {
+		return nil, fmt.Errorf("no attendee provided")
+	}
+
+	customer := strings.TrimSpace(attendee.Customer)
+	switch l := len(customer); {
+	case l == 0:
+		return nil, fmt.Errorf("no customer provided")
+	case l > maxCustomerLength:
+		return nil, fmt.Errorf("customer too long")
+	}
+
+	if len(attendee.Notes) > maxNotesLength {
+		return nil, fmt.Errorf("notes too long")
+	}
+
+	if _, err := d.GetClassSessionByID(sessionID); err != nil {
+		return nil, err
+	}
+
+	attendeeToCreate := &Attendee{
+		SessionID: sessionID,
+		Customer:  customer,
+		Notes:     attendee.Notes,
+	}
+
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		// The seat is taken only if one is left when the row is updated, so
+		// concurrent attendees cannot take more seats than there are.
+		res := tx.Model(&ClassSession{}).Where("id = ? AND booked < capacity", sessionID).
+			Update("booked", gorm.Expr("booked + 1"))
+		if res.Error != nil {
+			return fmt.Errorf("cannot take seat: %w", res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrSessionFull
+		}
+
+		if err := tx.Create(attendeeToCreate).Error; err != nil {
+			if isUniqueViolation(err) {
+				return ErrAlreadyAttending
+			}
+
+			return fmt.Errorf("cannot create attendee: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return attendeeToCreate.ToAPI(), nil
+}
+
+// RemoveAttendee frees the seat of the attendee of the session.
+func (d *Database) RemoveAttendee(sessionID, attendeeID uint) error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND session_id = ?", attendeeID, sessionID).Delete(&Attendee{})
+		if res.Error != nil {
+			return fmt.Errorf("cannot delete attendee: %w", res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Model(&ClassSession{}).Where("id = ? AND booked > 0", sessionID).
+			Update("booked", gorm.Expr("booked - 1")).Error; err != nil {
+			return fmt.Errorf("cannot free seat: %w", err)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,161 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+)
+
+func TestClassSessionToAPI(t *testing.T) {
+	cases := []struct {
+		name          string
+		capacity      uint
+		booked        uint
+		wantAvailable uint
+	}{
+		{name: "empty", capacity: 10, wantAvailable: 10},
+		{name: "partially booked", capacity: 10, booked: 4, wantAvailable: 6},
+		{name: "full", capacity: 10, booked: 10, wantAvailable: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := (&ClassSession{Capacity: tc.capacity, Booked: tc.booked}).ToAPI()
+			if got.Available != tc.wantAvailable {
+				t.Errorf("got %d available seats, want %d", got.Available, tc.wantAvailable)
+			}
+		})
+	}
+}
+
+func createTestSession(t *testing.T, d *Database, capacity uint) *types.ClassSession {
+	t.Helper()
+
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	session, err := d.CreateClassSession(&types.ClassSession{
+		ServiceID: 1,
+		Start:     start,
+		End:       start.Add(time.Hour),
+	}, capacity)
+	if err != nil {
+		t.Fatalf("cannot create session: %s", err)
+	}
+
+	return session
+}
+
+func TestAddAttendeeConcurrently(t *testing.T) {
+	d := testDatabase(t)
+
+	const (
+		capacity  = 3
+		attendees = 10
+	)
+	session := createTestSession(t, d, capacity)
+
+	var (
+		wg                sync.WaitGroup
+		lock              sync.Mutex
+		joined, full      int
+		unexpectedFailure error
+	)
+	for i := 0; i < attendees; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			_, err := d.AddAttendee(session.ID, &types.Attendee{Customer: fmt.Sprintf("customer %d", i)})
+
+			lock.Lock()
+			defer lock.Unlock()
+			switch {
+			case err == nil:
+				joined++
+			case errors.Is(err, ErrSessionFull):
+				full++
+			default:
+				unexpectedFailure = err
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if unexpectedFailure != nil {
+		t.Fatalf("unexpected error: %s", unexpectedFailure)
+	}
+
+	if joined != capacity || full != attendees-capacity {
+		t.Errorf("%d joined and %d found the session full, want %d and %d", joined, full, capacity, attendees-capacity)
+	}
+
+	got, err := d.GetClassSessionByID(session.ID)
+	if err != nil {
+		t.Fatalf("cannot get session: %s", err)
+	}
+
+	if got.Booked != capacity || got.Available != 0 {
+		t.Errorf("got %d booked and %d available seats, want %d and 0", got.Booked, got.Available, capacity)
+	}
+}
+
+func TestAddAttendeeTwice(t *testing.T) {
+	d := testDatabase(t)
+	session := createTestSession(t, d, 5)
+
+	if _, err := d.AddAttendee(session.ID, &types.Attendee{Customer: "Jane"}); err != nil {
+		t.Fatalf("cannot add attendee: %s", err)
+	}
+
+	if _, err := d.AddAttendee(session.ID, &types.Attendee{Customer: " Jane "}); !errors.Is(err, ErrAlreadyAttending) {
+		t.Fatalf("got error %v, want %v", err, ErrAlreadyAttending)
+	}
+
+	got, err := d.GetClassSessionByID(session.ID)
+	if err != nil {
+		t.Fatalf("cannot get session: %s", err)
+	}
+
+	// The seat taken by the second attempt is given back.
+	if got.Booked != 1 {
+		t.Errorf("got %d booked seats, want 1", got.Booked)
+	}
+}
+
+func TestRemoveAttendee(t *testing.T) {
+	d := testDatabase(t)
+	session := createTestSession(t, d, 1)
+
+	attendee, err := d.AddAttendee(session.ID, &types.Attendee{Customer: "Jane"})
+	if err != nil {
+		t.Fatalf("cannot add attendee: %s", err)
+	}
+
+	if _, err := d.AddAttendee(session.ID, &types.Attendee{Customer: "John"}); !errors.Is(err, ErrSessionFull) {
+		t.Fatalf("got error %v, want %v", err, ErrSessionFull)
+	}
+
+	if err := d.RemoveAttendee(session.ID, attendee.ID); err != nil {
+		t.Fatalf("cannot remove attendee: %s", err)
+	}
+
+	if err := d.RemoveAttendee(session.ID, attendee.ID); err == nil {
+		t.Error("removed the same attendee twice")
+	}
+
+	if _, err := d.AddAttendee(session.ID, &types.Attendee{Customer: "John"}); err != nil {
+		t.Fatalf("the freed seat cannot be taken: %s", err)
+	}
+
+	got, err := d.GetClassSessionByID(session.ID)
+	if err != nil {
+		t.Fatalf("cannot get session: %s", err)
+	}
+
+	if got.Booked != 1 {
+		t.Errorf("got %d booked seats, want 1", got.Booked)
+	}
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDSNEnv names the variable with the DSN of an empty Postgres database
+// the tests can use. The tests that need one are skipped without it.
+const testDSNEnv string = "APPOINTMENTS_TEST_DSN"
+
+// testDatabase returns the migrated test database, which is emptied when
+// the test ends.
+func testDatabase(t *testing.T) *Database {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("cannot connect to the test database: %s", err)
+	}
+
+	d := &Database{DB: db, Logger: zerolog.Nop()}
+	if err := d.Migrate(); err != nil {
+		t.Fatalf("cannot migrate the test database: %s", err)
+	}
+
+	t.Cleanup(func() {
+		if err := db.Exec("TRUNCATE " + bookingsTable + ", " + seriesTable + ", " + waitlistTable + ", " +
+			classSessionsTable + ", " + classAttendeesTable + ", " + chainsTable + ", " +
+			bookingTransitionsTable + " RESTART IDENTITY CASCADE").Error; err != nil {
+			t.Errorf("cannot empty the test database: %s", err)
+		}
+	})
+
+	return d
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrHoldExpired is returned when confirming a hold that expired.
+	ErrHoldExpired = errors.New("the hold expired")
+)
+
+// GetHoldByID returns the hold, even if it expired but was not swept yet.
+func (d *Database) GetHoldByID(id uint) (*types.Hold, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	var hold Booking
+	if err := d.DB.Model(&Booking{}).
+		Scopes(byBookingID(id), held()).First(&hold).Error; err != nil {
+		return nil, err
+	}
+
+	return hold.ToHold(), nil
+}
+
+// CreateHold takes the time of the hold, with the buffers of its service,
+// for ttl. If the time is taken, a *ConflictError is returned.
+func (d *Database) CreateHold(hold *types.Hold, ttl, bufferBefore, bufferAfter time.Duration) (*types.Hold, error) {
+	if hold == nil {
+		return nil, fmt.Errorf("no hold provided")
+	}
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid ttl provided")
+	}
+
+	if hold.ServiceID == 0 {
+		return nil, fmt.Errorf("no service provided")
+	}
+
+	if hold.Start.IsZero() || !hold.End.After(hold.Start) {
+		return nil, fmt.Errorf("invalid start or end provided")
+	}
+
+	if bufferBefore < 0 || bufferAfter < 0 {
+		return nil, fmt.Errorf("invalid buffers provided")
+	}
+
+	holdToCreate := &Booking{
+		ServiceID:     hold.ServiceID,
+		StartsAt:      hold.Start,
+		EndsAt:        hold.End,
+		BlockedFrom:   hold.Start.Add(-bufferBefore),
+		BlockedUntil:  hold.End.Add(bufferAfter),
+		HoldExpiresAt: sqlNullTime(time.Now().Add(ttl)),
+	}
+
+	if err := d.clearExpiredHolds(holdToCreate); err != nil {
+		return nil, err
+	}
+
+	if err := d.DB.Create(holdToCreate).Error; err != nil {
+		return nil, d.conflictError(err, holdToCreate)
+	}
+
+	return holdToCreate.ToHold(), nil
+}
+
+// ConfirmHold turns the hold into a booking for the customer, with the
+// notes and the status, all taken from booking. The time of the hold cannot
+// be changed.
+func (d *Database) ConfirmHold(id uint, booking *types.Booking) (*types.Booking, error) {
+	if booking == nil {
+		return nil, fmt.Errorf("no booking provided")
+	}
+
+	hold, err := d.GetHoldByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	toConfirm, err := checkBookingBeforePut(&types.Booking{
+		ServiceID: hold.ServiceID,
+		Start:     hold.Start,
+		End:       hold.End,
+		Customer:  booking.Customer,
+		Notes:     booking.Notes,
+		Status:    booking.Status,
+	}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		// The check on the expiration makes sure that a hold that expires in
+		// the meantime is not confirmed.
+		res := tx.Model(&Booking{}).Scopes(byBookingID(id), held(), takingTime(time.Now())).
+			Updates(map[string]interface{}{
+				"hold_expires_at": nil,
+				"customer":        toConfirm.Customer,
+				"notes":           toConfirm.Notes,
+				"status":          toConfirm.Status,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("cannot confirm hold: %w", res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrHoldExpired
+		}
+
+		return d.recordCreation(tx, id, toConfirm.Status)
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.GetBookingByID(id)
+}
+
+// ReleaseHold frees the time of the hold.
+func (d *Database) ReleaseHold(id uint) error {
+	if _, err := d.GetHoldByID(id); err != nil {
+		return err
+	}
+
+	if err := d.DB.Unscoped().Scopes(byBookingID(id), held()).Delete(&Booking{}).Error; err != nil {
+		return fmt.Errorf("cannot release hold: %w", err)
+	}
+
+	return nil
+}
+
+// ExpireHolds deletes the holds that expired by now and returns how many
+// were deleted.
+func (d *Database) ExpireHolds(now time.Time) (int64, error) {
+	res := d.DB.Unscoped().Scopes(held(), expiredHolds(now)).Delete(&Booking{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("cannot expire holds: %w", res.Error)
+	}
+
+	return res.RowsAffected, nil
+}
+
+// GetBusy returns the time taken by the bookings and the holds of the
+// service from start to end, buffers included.
+func (d *Database) GetBusy(serviceID uint, start, end time.Time) ([]interval.Interval, error) {
+	rows := []Booking{}
+	if err := d.DB.Model(&Booking{}).
+		Scopes(byServiceID(serviceID), blocking(start, end), takingTime(time.Now()), active()).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	busy := make([]interval.Interval, len(rows))
+	for i, row := range rows {
+		busy[i] = interval.Interval{Start: row.BlockedFrom, End: row.BlockedUntil}
+	}
+
+	return interval.Normalize(busy), nil
+}
+
+// clearExpiredHolds deletes the expired holds that would take the time of
+// booking, so that they do not stand in its way until the sweeper removes
+// them.
+func (d *Database) clearExpiredHolds(booking *Booking) error {
+	if err := d.DB.Unscoped().
+		Scopes(byServiceID(booking.ServiceID), blocking(booking.BlockedFrom, booking.BlockedUntil),
+			held(), expiredHolds(time.Now())).
+		Delete(&Booking{}).Error; err != nil {
+		return fmt.Errorf("cannot delete expired holds: %w", err)
+	}
+
+	return nil
+}
+
+func sqlNullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxReportedOverlaps is how many overlapping pairs of rows are listed
+	// when a constraint cannot be created because of them.
+	maxReportedOverlaps int = 50
+)
+
+// Migrate creates the tables or brings them up to date, including the
+// constraints that prevent overlapping bookings and class sessions of the
+// same service.
+func (d *Database) Migrate() error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(&Booking{}, &Series{}, &WaitlistEntry{}, &ClassSession{}, &Attendee{}, &Chain{}, &BookingTransition{}); err != nil {
+			return fmt.Errorf("cannot migrate tables: %w", err)
+		}
+
+		// Bookings created before the blocked times existed take their
+		// own time only.
+		if err := tx.Exec("UPDATE bookings SET blocked_from = starts_at, blocked_until = ends_at WHERE blocked_from IS NULL OR blocked_until IS NULL").Error; err != nil {
+			return fmt.Errorf("cannot fill blocked times: %w", err)
+		}
+
+		for _, column := range []string{"blocked_from", "blocked_until"} {
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE bookings ALTER COLUMN %s SET NOT NULL", column)).Error; err != nil {
+				return fmt.Errorf("cannot require %s: %w", column, err)
+			}
+		}
+
+		if err := tx.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist").Error; err != nil {
+			return fmt.Errorf("cannot create btree_gist extension: %w", err)
+		}
+
+		// Declined and cancelled bookings do not take time anymore: the
+		// constraint that did not know about statuses is replaced.
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE bookings DROP CONSTRAINT IF EXISTS %s",
+			legacyBookingsOverlapConstraint)).Error; err != nil {
+			return fmt.Errorf("cannot drop constraint %s: %w", legacyBookingsOverlapConstraint, err)
+		}
+
+		if err := addOverlapConstraint(tx, bookingsTable, bookingsOverlapConstraint,
+			"blocked_from", "blocked_until", "deleted_at IS NULL AND status NOT IN ('declined', 'cancelled')"); err != nil {
+			return err
+		}
+
+		return addOverlapConstraint(tx, classSessionsTable, sessionsOverlapConstraint,
+			"starts_at", "ends_at", "deleted_at IS NULL")
+	})
+}
+
+// addOverlapConstraint prevents the rows of the table with the same service
+// that satisfy where from having overlapping ranges from startColumn to
+// endColumn, unless the constraint already exists.
+func addOverlapConstraint(tx *gorm.DB, table, constraint, startColumn, endColumn, where string) error {
+	count := int64(0)
+	if err := tx.Raw("SELECT COUNT(*) FROM pg_constraint WHERE conname = ?", constraint).
+		Scan(&count).Error; err != nil {
+		return fmt.Errorf("cannot check constraints: %w", err)
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	// Rows written before the constraint existed may overlap, which would
+	// make adding it fail with no hint about which rows to fix.
+	overlapping := []struct {
+		First  uint
+		Second uint
+	}{}
+	if err := tx.Raw(fmt.Sprintf("WITH ranges AS (SELECT id, service_id, tstzrange(%s, %s, '[)') AS time_range FROM %s WHERE (%s)) "+
+		"SELECT a.id AS first, b.id AS second FROM ranges a JOIN ranges b "+
+		"ON a.service_id = b.service_id AND a.id < b.id AND a.time_range && b.time_range "+
+		"ORDER BY a.id, b.id LIMIT %d",
+		startColumn, endColumn, table, where, maxReportedOverlaps)).Scan(&overlapping).Error; err != nil {
+		return fmt.Errorf("cannot check overlapping rows of %s: %w", table, err)
+	}
+
+	if len(overlapping) > 0 {
+		pairs := make([]string, len(overlapping))
+		for i, pair := range overlapping {
+			pairs[i] = fmt.Sprintf("%d and %d", pair.First, pair.Second)
+		}
+
+		return fmt.Errorf("cannot create constraint %s: these rows of %s overlap and must be moved or deleted first: %s",
+			constraint, table, strings.Join(pairs, ", "))
+	}
+
+	if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s EXCLUDE USING gist "+
+		"(service_id WITH =, tstzrange(%s, %s, '[)') WITH &&) WHERE (%s)",
+		table, constraint, startColumn, endColumn, where)).Error; err != nil {
+		return fmt.Errorf("cannot create constraint %s: %w", constraint, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+type Booking struct {
+	gorm.Model
+	ServiceID uint      `gorm:"not null;index"`
+	StartsAt  time.Time `gorm:"not null"`
+	EndsAt    time.Time `gorm:"not null;check:chk_bookings_ends_at,ends_at > starts_at"`
+	Customer  string    `gorm:"size:100"`
+	Notes     string    `gorm:"size:300"`
+	// BlockedFrom and BlockedUntil are the time taken by the booking,
+	// including the buffers of the service. Bookings of the same service
+	// cannot take the same time.
+	BlockedFrom  time.Time
+	BlockedUntil time.Time
+	// HoldExpiresAt is set while the row is a hold rather than a booking:
+	// it takes its time until then, unless it is confirmed.
+	HoldExpiresAt sql.NullTime `gorm:"index"`
+	// SeriesID and OccurrenceAt are set on the occurrences of a series:
+	// OccurrenceAt is when the rule of the series places the occurrence.
+	SeriesID     *uint `gorm:"index"`
+	OccurrenceAt sql.NullTime
+	ChainID      *uint               `gorm:"index"`
+	Reschedules  uint                `gorm:"not null;default:0"`
+	Fees         float64             `gorm:"not null;default:0"`
+	Status       types.BookingStatus `gorm:"size:20;not null;default:confirmed;index"`
+}
+
+func (b *Booking) ToAPI() *types.Booking {
+	return &types.Booking{
+		ID:        b.ID,
+		CreatedAt: b.CreatedAt,
+		UpdatedAt: b.UpdatedAt,
+		DeletedAt: func() *time.Time {
+			var deleted time.Time
+			if !b.DeletedAt.Valid {
+				return nil
+			}
+
+			deleted = b.DeletedAt.Time
+			return &deleted
+		}(),
+		ServiceID:   b.ServiceID,
+		Start:       b.StartsAt,
+		End:         b.EndsAt,
+		Customer:    b.Customer,
+		Notes:       b.Notes,
+		SeriesID:    b.SeriesID,
+		ChainID:     b.ChainID,
+		Reschedules: b.Reschedules,
+		Fees:        b.Fees,
+		Status:      b.Status,
+	}
+}
+
+func (b *Booking) TableName() string {
+	return bookingsTable
+}
+
+func (b *Booking) ToHold() *types.Hold {
+	return &types.Hold{
+		ID:        b.ID,
+		CreatedAt: b.CreatedAt,
+		ServiceID: b.ServiceID,
+		Start:     b.StartsAt,
+		End:       b.EndsAt,
+		ExpiresAt: b.HoldExpiresAt.Time,
+	}
+}
+
+type Series struct {
+	gorm.Model
+	ServiceID uint      `gorm:"not null;index"`
+	StartsAt  time.Time `gorm:"not null"`
+	EndsAt    time.Time `gorm:"not null;check:chk_booking_series_ends_at,ends_at > starts_at"`
+	RRule     string    `gorm:"size:500;not null"`
+	Customer  string    `gorm:"size:100"`
+	Notes     string    `gorm:"size:300"`
+}
+
+func (s *Series) ToAPI() *types.Series {
+	return &types.Series{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		DeletedAt: func() *time.Time {
+			var deleted time.Time
+			if !s.DeletedAt.Valid {
+				return nil
+			}
+
+			deleted = s.DeletedAt.Time
+			return &deleted
+		}(),
+		ServiceID: s.ServiceID,
+		Start:     s.StartsAt,
+		End:       s.EndsAt,
+		RRule:     s.RRule,
+		Customer:  s.Customer,
+		Notes:     s.Notes,
+	}
+}
+
+func (s *Series) TableName() string {
+	return seriesTable
+}
+
+type WaitlistEntry struct {
+	gorm.Model
+	ServiceID  uint                 `gorm:"not null;index"`
+	WindowFrom time.Time            `gorm:"not null"`
+	WindowTo   time.Time            `gorm:"not null;check:chk_waitlist_entries_window_to,window_to > window_from"`
+	Customer   string               `gorm:"size:100"`
+	Notes      string               `gorm:"size:300"`
+	Status     types.WaitlistStatus `gorm:"size:20;not null;default:waiting;index"`
+	// HoldID, OfferStart, OfferEnd and OfferExpiresAt describe the last
+	// offer made to the entry.
+	HoldID         *uint
+	OfferStart     sql.NullTime
+	OfferEnd       sql.NullTime
+	OfferExpiresAt sql.NullTime `gorm:"index"`
+	BookingID      *uint
+}
+
+func (w *WaitlistEntry) ToAPI() *types.WaitlistEntry {
+	entry := &types.WaitlistEntry{
+		ID:        w.ID,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+		ServiceID: w.ServiceID,
+		From:      w.WindowFrom,
+		To:        w.WindowTo,
+		Customer:  w.Customer,
+		Notes:     w.Notes,
+		Status:    w.Status,
+		BookingID: w.BookingID,
+	}
+
+	if w.HoldID != nil {
+		entry.Offer = &types.WaitlistOffer{
+			HoldID:    *w.HoldID,
+			Start:     w.OfferStart.Time,
+			End:       w.OfferEnd.Time,
+			ExpiresAt: w.OfferExpiresAt.Time,
+		}
+	}
+
+	return entry
+}
+
+func (w *WaitlistEntry) TableName() string {
+	return waitlistTable
+}
+
+type ClassSession struct {
+	gorm.Model
+	ServiceID uint      `gorm:"not null;index"`
+	StartsAt  time.Time `gorm:"not null"`
+	EndsAt    time.Time `gorm:"not null;check:chk_class_sessions_ends_at,ends_at > starts_at"`
+	Capacity  uint      `gorm:"not null"`
+	// Booked is the number of attendees, kept up to date with them so that
+	// seats can be taken with a single conditional update.
+	Booked uint `gorm:"not null;default:0;check:chk_class_sessions_booked,booked <= capacity"`
+}
+
+func (s *ClassSession) ToAPI() *types.ClassSession {
+	return &types.ClassSession{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		UpdatedAt: s.UpdatedAt,
+		ServiceID: s.ServiceID,
+		Start:     s.StartsAt,
+		End:       s.EndsAt,
+		Capacity:  s.Capacity,
+		Booked:    s.Booked,
+		Available: s.Capacity - s.Booked,
+	}
+}
+
+func (s *ClassSession) TableName() string {
+	return classSessionsTable
+}
+
+type Attendee struct {
+	gorm.Model
+	SessionID uint   `gorm:"not null;uniqueIndex:idx_class_attendees_customer,where:deleted_at IS NULL"`
+	Customer  string `gorm:"size:100;not null;uniqueIndex:idx_class_attendees_customer,where:deleted_at IS NULL"`
+	Notes     string `gorm:"size:300"`
+}
+
+func (a *Attendee) ToAPI() *types.Attendee {
+	return &types.Attendee{
+		ID:        a.ID,
+		CreatedAt: a.CreatedAt,
+		SessionID: a.SessionID,
+		Customer:  a.Customer,
+		Notes:     a.Notes,
+	}
+}
+
+func (a *Attendee) TableName() string {
+	return classAttendeesTable
+}
+
+type Chain struct {
+	gorm.Model
+	Customer string `gorm:"size:100"`
+	Notes    string `gorm:"size:300"`
+}
+
+func (c *Chain) ToAPI() *types.Chain {
+	return &types.Chain{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		DeletedAt: func() *time.Time {
+			var deleted time.Time
+			if !c.DeletedAt.Valid {
+				return nil
+			}
+
+			deleted = c.DeletedAt.Time
+			return &deleted
+		}(),
+		Customer: c.Customer,
+		Notes:    c.Notes,
+	}
+}
+
+func (c *Chain) TableName() string {
+	return chainsTable
+}
+
+type BookingTransition struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	BookingID uint                `gorm:"not null;index"`
+	From      types.BookingStatus `gorm:"size:20;not null"`
+	To        types.BookingStatus `gorm:"size:20;not null"`
+	Actor     string              `gorm:"size:100"`
+}
+
+func (t *BookingTransition) ToAPI() *types.BookingTransition {
+	return &types.BookingTransition{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		BookingID: t.BookingID,
+		From:      t.From,
+		To:        t.To,
+		Actor:     t.Actor,
+	}
+}
+
+func (t *BookingTransition) TableName() string {
+	return bookingTransitionsTable
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+const (
+	maxCustomerLength int = 100
+	maxNotesLength    int = 300
+
+	bookingsTable             string = "bookings"
+	bookingsOverlapConstraint string = "bookings_no_overlap_active"
+	// legacyBookingsOverlapConstraint also included declined and cancelled
+	// bookings.
+	legacyBookingsOverlapConstraint string = "bookings_no_overlap"
+	seriesTable                     string = "booking_series"
+	waitlistTable                   string = "waitlist_entries"
+	classSessionsTable              string = "class_sessions"
+	chainsTable                     string = "booking_chains"
+	bookingTransitionsTable         string = "booking_transitions"
+	classAttendeesTable             string = "class_attendees"
+	sessionsOverlapConstraint       string = "class_sessions_no_overlap"
+	uniqueViolationCode             string = "23505"
+	maxRRuleLength                  int    = 500
+
+	exclusionViolationCode string = "23P01"
+)
+
+var (
+	// ErrConflict is returned when a booking would take the time of another
+	// one of the same service.
+	ErrConflict = errors.New("the time is already booked")
+	// ErrChanged is returned when a booking was changed while being
+	// rescheduled.
+	ErrChanged = errors.New("the booking was changed in the meantime")
+	// ErrInvalidTransition is returned when a booking cannot get a status
+	// from the one it has.
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// ConflictError is returned when a booking would take the time of another
+// one, which is Booking. Held is true if that one is a hold.
+type ConflictError struct {
+	Booking *types.Booking
+	Held    bool
+}
+
+func (e *ConflictError) Error() string {
+	what := "booking"
+	if e.Held {
+		what = "hold"
+	}
+
+	return fmt.Sprintf("%s by %s %d, from %s to %s", ErrConflict, what, e.Booking.ID,
+		e.Booking.Start.Format(time.RFC3339), e.Booking.End.Format(time.RFC3339))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+type Database struct {
+	DB     *gorm.DB
+	Logger zerolog.Logger
+	// Actor is who makes the changes to statuses, if known.
+	Actor string
+}
+
+// WithActor returns a copy of the database that records status changes as
+// made by the provided actor.
+func (d *Database) WithActor(actor string) *Database {
+	withActor := *d
+	withActor.Actor = actor
+	return &withActor
+}
+
+func (d *Database) GetBookingByID(id uint) (*types.Booking, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	var booking Booking
+	if err := d.DB.Model(&Booking{}).
+		Scopes(byBookingID(id), booked()).First(&booking).Error; err != nil {
+		return nil, err
+	}
+
+	return booking.ToAPI(), nil
+}
+
+// GetBookings returns the bookings that overlap the interval from start to
+// end, sorted by start. If serviceID is 0, the bookings of all the services
+// are returned.
+func (d *Database) GetBookings(serviceID uint, start, end time.Time) ([]types.Booking, error) {
+	query := d.DB.Order("starts_at asc").Model(&Booking{}).
+		Scopes(overlapping(start, end), booked())
+	if serviceID != 0 {
+		query = query.Scopes(byServiceID(serviceID))
+	}
+
+	bookings := []Booking{}
+	if err := query.Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.Booking, len(bookings))
+	for i := 0; i < len(bookings); i++ {
+		converted[i] = *bookings[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// CreateBooking creates the booking, keeping the buffers of its service
+// free as well. If the time is taken, a *ConflictError is returned.
+func (d *Database) CreateBooking(booking *types.Booking, bufferBefore, bufferAfter time.Duration) (*types.Booking, error) {
+	if booking == nil {
+		return nil, fmt.Errorf("no booking provided")
+	}
+
+	bookingToCreate, err := checkBookingBeforePut(booking, bufferBefore, bufferAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.clearExpiredHolds(bookingToCreate); err != nil {
+		return nil, err
+	}
+
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bookingToCreate).Error; err != nil {
+			return err
+		}
+
+		return d.recordCreation(tx, bookingToCreate.ID, bookingToCreate.Status)
+	}); err != nil {
+		return nil, d.conflictError(err, bookingToCreate)
+	}
+
+	return bookingToCreate.ToAPI(), nil
+}
+
+// UpdateBooking changes the customer and the notes of the booking. Its
+// time can only be changed by rescheduling it.
+func (d *Database) UpdateBooking(booking *types.Booking) (*types.Booking, error) {
+	if booking == nil {
+		return nil, fmt.Errorf("no booking provided")
+	}
+
+	existing, err := d.GetBookingByID(booking.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if booking.ServiceID != 0 && booking.ServiceID != existing.ServiceID {
+		return nil, fmt.Errorf("cannot change the service of a booking")
+	}
+
+	bookingToUpdate, err := checkBookingBeforePut(&types.Booking{
+		ServiceID: existing.ServiceID,
+		Start:     existing.Start,
+		End:       existing.End,
+		Customer:  booking.Customer,
+		Notes:     booking.Notes,
+		Status:    existing.Status,
+	}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.DB.Model(&Booking{}).Scopes(byBookingID(existing.ID), booked()).
+		Select("customer", "notes").Updates(bookingToUpdate).Error; err != nil {
+		return nil, fmt.Errorf("cannot update booking: %w", err)
+	}
+
+	return d.GetBookingByID(existing.ID)
+}
+
+// Cancellation is the cancellation of a booking, charging Fee.
+type Cancellation struct {
+	BookingID uint
+	Fee       float64
+}
+
+// CancelBooking cancels the booking, charging fee.
+func (d *Database) CancelBooking(id uint, fee float64) (*types.Booking, error) {
+	if fee < 0 {
+		return nil, fmt.Errorf("invalid fee provided")
+	}
+
+	return d.transition(id, types.BookingCancelled, map[string]interface{}{
+		"fees": gorm.Expr("fees + ?", fee),
+	})
+}
+
+// RescheduleBooking moves the booking to the start and end of booking,
+// charging fee. reschedules is how many times the booking was moved when
+// the fee was decided: if it was moved since, ErrChanged is returned. If
+// the time is taken, a *ConflictError is returned.
+func (d *Database) RescheduleBooking(booking *types.Booking, reschedules uint, fee float64, bufferBefore, bufferAfter time.Duration) (*types.Booking, error) {
+	if booking == nil {
+		return nil, fmt.Errorf("no booking provided")
+	}
+
+	if fee < 0 {
+		return nil, fmt.Errorf("invalid fee provided")
+	}
+
+	existing, err := d.GetBookingByID(booking.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMovable(existing); err != nil {
+		return nil, err
+	}
+
+	moved, err := checkBookingBeforePut(&types.Booking{
+		ServiceID: existing.ServiceID,
+		Start:     booking.Start,
+		End:       booking.End,
+		Customer:  existing.Customer,
+		Notes:     existing.Notes,
+		Status:    existing.Status,
+	}, bufferBefore, bufferAfter)
+	if err != nil {
+		return nil, err
+	}
+	moved.ID = existing.ID
+
+	if err := d.clearExpiredHolds(moved); err != nil {
+		return nil, err
+	}
+
+	res := d.DB.Model(&Booking{}).Scopes(byBookingID(existing.ID), booked()).
+		Where("reschedules = ? AND status = ?", reschedules, existing.Status).
+		Updates(map[string]interface{}{
+			"starts_at":     moved.StartsAt,
+			"ends_at":       moved.EndsAt,
+			"blocked_from":  moved.BlockedFrom,
+			"blocked_until": moved.BlockedUntil,
+			"reschedules":   gorm.Expr("reschedules + 1"),
+			"fees":          gorm.Expr("fees + ?", fee),
+		})
+	if res.Error != nil {
+		return nil, d.conflictError(res.Error, moved)
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, ErrChanged
+	}
+
+	return d.GetBookingByID(existing.ID)
+}
+
+// conflictError converts a violation of the overlap constraint to a
+// *ConflictError with the booking that takes the time of booking. Other
+// errors are returned as they are.
+func (d *Database) conflictError(err error, booking *Booking) error {
+	if !isExclusionViolation(err) {
+		return err
+	}
+
+	var existing Booking
+	if findErr := d.DB.Model(&Booking{}).
+		Scopes(byServiceID(booking.ServiceID), blocking(booking.BlockedFrom, booking.BlockedUntil), active()).
+		Where("id <> ?", booking.ID).
+		Order("starts_at asc").First(&existing).Error; findErr != nil {
+		// The conflicting booking may have been deleted in the meantime.
+		return fmt.Errorf("%w: %s", ErrConflict, err)
+	}
+
+	return &ConflictError{Booking: existing.ToAPI(), Held: existing.HoldExpiresAt.Valid}
+}
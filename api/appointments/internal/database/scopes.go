@@ -0,0 +1,94 @@
+package database
+
+import (
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+func byBookingID(id uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("id = ?", id)
+	}
+}
+
+func byServiceID(serviceID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("service_id = ?", serviceID)
+	}
+}
+
+// overlapping selects the rows whose interval has some time in common with
+// the one from start to end.
+func overlapping(start, end time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("starts_at < ? AND ends_at > ?", end, start)
+	}
+}
+
+// blocking selects the rows whose blocked time has some time in common with
+// the one from start to end.
+func blocking(start, end time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("blocked_from < ? AND blocked_until > ?", end, start)
+	}
+}
+
+// booked selects the rows that are bookings and not holds.
+func booked() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("hold_expires_at IS NULL")
+	}
+}
+
+// held selects the rows that are holds, expired or not.
+func held() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("hold_expires_at IS NOT NULL")
+	}
+}
+
+// expiredHolds selects the holds that expired by now.
+func expiredHolds(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("hold_expires_at <= ?", now)
+	}
+}
+
+// active selects the rows that were not declined or cancelled.
+func active() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("status NOT IN ?", []types.BookingStatus{types.BookingDeclined, types.BookingCancelled})
+	}
+}
+
+// takingTime selects the bookings and the holds that did not expire by now.
+func takingTime(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("hold_expires_at IS NULL OR hold_expires_at > ?", now)
+	}
+}
+
+func bySeriesID(seriesID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("series_id = ?", seriesID)
+	}
+}
+
+func byWaitlistStatus(status types.WaitlistStatus) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("status = ?", status)
+	}
+}
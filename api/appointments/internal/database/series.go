@@ -0,0 +1,209 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+// GetSeriesByID returns the series with all its occurrences, skipped ones
+// included, sorted by when the rule places them.
+func (d *Database) GetSeriesByID(id uint) (*types.Series, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	var series Series
+	if err := d.DB.Model(&Series{}).Where("id = ?", id).First(&series).Error; err != nil {
+		return nil, err
+	}
+
+	bookings := []Booking{}
+	if err := d.DB.Unscoped().Order("occurrence_at asc").Model(&Booking{}).
+		Scopes(bySeriesID(id)).Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	converted := series.ToAPI()
+	converted.Occurrences = make([]types.Occurrence, len(bookings))
+	for i := 0; i < len(bookings); i++ {
+		occurrence := types.Occurrence{
+			At:      bookings[i].OccurrenceAt.Time,
+			Status:  types.OccurrenceScheduled,
+			Booking: bookings[i].ToAPI(),
+		}
+
+		switch {
+		case bookings[i].DeletedAt.Valid, !bookings[i].Status.TakesTime():
+			occurrence.Status = types.OccurrenceSkipped
+		case !bookings[i].StartsAt.Equal(bookings[i].OccurrenceAt.Time):
+			occurrence.Status = types.OccurrenceMoved
+		}
+
+		converted.Occurrences[i] = occurrence
+	}
+
+	return converted, nil
+}
+
+// CreateSeries creates the series and books all its occurrences, which
+// start at the provided times, last as much as the first one and have the
+// provided status. Either all of them are booked or none is: if the time of
+// one is taken, a *ConflictError is returned.
+func (d *Database) CreateSeries(series *types.Series, occurrences []time.Time, status types.BookingStatus, bufferBefore, bufferAfter time.Duration) (*types.Series, error) {
+	if series == nil {
+		return nil, fmt.Errorf("no series provided")
+	}
+
+	rule := strings.TrimSpace(series.RRule)
+	switch l := len(rule); {
+	case l == 0:
+		return nil, fmt.Errorf("no rrule provided")
+	case l > maxRRuleLength:
+		return nil, fmt.Errorf("rrule too long")
+	}
+
+	if len(occurrences) == 0 {
+		return nil, fmt.Errorf("no occurrences provided")
+	}
+
+	duration := series.End.Sub(series.Start)
+	bookings := make([]*Booking, len(occurrences))
+	for i, start := range occurrences {
+		booking, err := checkBookingBeforePut(&types.Booking{
+			ServiceID: series.ServiceID,
+			Start:     start,
+			End:       start.Add(duration),
+			Customer:  series.Customer,
+			Notes:     series.Notes,
+			Status:    status,
+		}, bufferBefore, bufferAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		booking.OccurrenceAt = sql.NullTime{Time: start, Valid: true}
+		bookings[i] = booking
+	}
+
+	for _, booking := range bookings {
+		if err := d.clearExpiredHolds(booking); err != nil {
+			return nil, err
+		}
+	}
+
+	seriesToCreate := &Series{
+		ServiceID: series.ServiceID,
+		StartsAt:  series.Start,
+		EndsAt:    series.End,
+		RRule:     rule,
+		Customer:  bookings[0].Customer,
+		Notes:     series.Notes,
+	}
+
+	var failed *Booking
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(seriesToCreate).Error; err != nil {
+			return fmt.Errorf("cannot create series: %w", err)
+		}
+
+		for _, booking := range bookings {
+			booking.SeriesID = &seriesToCreate.ID
+			if err := tx.Create(booking).Error; err != nil {
+				failed = booking
+				return err
+			}
+
+			if err := d.recordCreation(tx, booking.ID, booking.Status); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		if failed != nil {
+			// The transaction is over, so the conflicting booking can be
+			// looked for.
+			failed.ID = 0
+			return nil, d.conflictError(err, failed)
+		}
+
+		return nil, err
+	}
+
+	return d.GetSeriesByID(seriesToCreate.ID)
+}
+
+// GetOccurrence returns the booking of the series, if it was not skipped.
+func (d *Database) GetOccurrence(seriesID, bookingID uint) (*types.Booking, error) {
+	var booking Booking
+	if err := d.DB.Model(&Booking{}).
+		Scopes(byBookingID(bookingID), bySeriesID(seriesID), booked()).
+		First(&booking).Error; err != nil {
+		return nil, err
+	}
+
+	return booking.ToAPI(), nil
+}
+
+// MoveOccurrence reschedules the occurrence of the series from start to
+// end, charging fee, as RescheduleBooking does.
+func (d *Database) MoveOccurrence(seriesID, bookingID uint, start, end time.Time, reschedules uint, fee float64, bufferBefore, bufferAfter time.Duration) (*types.Booking, error) {
+	if _, err := d.GetOccurrence(seriesID, bookingID); err != nil {
+		return nil, err
+	}
+
+	return d.RescheduleBooking(&types.Booking{
+		ID:    bookingID,
+		Start: start,
+		End:   end,
+	}, reschedules, fee, bufferBefore, bufferAfter)
+}
+
+// SkipOccurrence cancels the occurrence of the series, charging fee and
+// freeing its time.
+func (d *Database) SkipOccurrence(seriesID, bookingID uint, fee float64) (*types.Booking, error) {
+	if _, err := d.GetOccurrence(seriesID, bookingID); err != nil {
+		return nil, err
+	}
+
+	return d.CancelBooking(bookingID, fee)
+}
+
+// CancelSeries cancels the series and the provided occurrences, charging
+// their fees. Either all of them are cancelled or none is. The occurrences
+// that are not provided, like the ones that already started, are kept.
+func (d *Database) CancelSeries(id uint, cancellations []Cancellation) ([]types.Booking, error) {
+	if _, err := d.GetSeriesByID(id); err != nil {
+		return nil, err
+	}
+
+	cancelled := make([]types.Booking, len(cancellations))
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		inTx := *d
+		inTx.DB = tx
+
+		for i, cancellation := range cancellations {
+			booking, err := inTx.SkipOccurrence(id, cancellation.BookingID, cancellation.Fee)
+			if err != nil {
+				return err
+			}
+
+			cancelled[i] = *booking
+		}
+
+		if err := tx.Where("id = ?", id).Delete(&Series{}).Error; err != nil {
+			return fmt.Errorf("cannot delete series: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+// TransitionBooking moves the booking to the provided status, recording
+// who did it. A booking can only be completed or marked as a no-show once
+// it has started, and it can only be cancelled with CancelBooking, which
+// charges the fee of the cancellation.
+func (d *Database) TransitionBooking(id uint, to types.BookingStatus, now time.Time) (*types.Booking, error) {
+	if !to.IsValid() {
+		return nil, fmt.Errorf("invalid status provided")
+	}
+
+	if to == types.BookingCancelled {
+		return nil, fmt.Errorf("%w: bookings must be cancelled with their fee", ErrInvalidTransition)
+	}
+
+	if to == types.BookingCompleted || to == types.BookingNoShow {
+		existing, err := d.GetBookingByID(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if now.Before(existing.Start) {
+			return nil, fmt.Errorf("%w: the booking has not started yet", ErrInvalidTransition)
+		}
+	}
+
+	return d.transition(id, to, nil)
+}
+
+// GetTransitions returns the status changes of the booking, oldest first.
+func (d *Database) GetTransitions(id uint) ([]types.BookingTransition, error) {
+	if _, err := d.GetBookingByID(id); err != nil {
+		return nil, err
+	}
+
+	transitions := []BookingTransition{}
+	if err := d.DB.Order("created_at asc, id asc").Model(&BookingTransition{}).
+		Where("booking_id = ?", id).Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.BookingTransition, len(transitions))
+	for i := 0; i < len(transitions); i++ {
+		converted[i] = *transitions[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// recordCreation records, in tx, that the booking was created with the
+// provided status, which is the first transition of the booking.
+func (d *Database) recordCreation(tx *gorm.DB, id uint, status types.BookingStatus) error {
+	if err := tx.Create(&BookingTransition{
+		BookingID: id,
+		To:        status,
+		Actor:     d.Actor,
+	}).Error; err != nil {
+		return fmt.Errorf("cannot record transition: %w", err)
+	}
+
+	return nil
+}
+
+// transition moves the booking to the provided status, also applying
+// updates, and records the change. If the status of the booking changes in
+// the meantime, ErrChanged is returned.
+func (d *Database) transition(id uint, to types.BookingStatus, updates map[string]interface{}) (*types.Booking, error) {
+	existing, err := d.GetBookingByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !existing.Status.CanBecome(to) {
+		return nil, fmt.Errorf("%w: from %s to %s", ErrInvalidTransition, existing.Status, to)
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = to
+
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Booking{}).Scopes(byBookingID(id), booked()).
+			Where("status = ?", existing.Status).Updates(updates)
+		if res.Error != nil {
+			return fmt.Errorf("cannot change status: %w", res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrChanged
+		}
+
+		if err := tx.Create(&BookingTransition{
+			BookingID: id,
+			From:      existing.Status,
+			To:        to,
+			Actor:     d.Actor,
+		}).Error; err != nil {
+			return fmt.Errorf("cannot record transition: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.GetBookingByID(id)
+}
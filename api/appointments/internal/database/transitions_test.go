@@ -0,0 +1,303 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+)
+
+// transitionSteps returns the statuses and the actor of the transitions of
+// the booking, formatted as "from>to by actor".
+func transitionSteps(t *testing.T, d *Database, id uint) []string {
+	t.Helper()
+
+	transitions, err := d.GetTransitions(id)
+	if err != nil {
+		t.Fatalf("cannot get transitions: %s", err)
+	}
+
+	steps := []string{}
+	for _, transition := range transitions {
+		steps = append(steps, string(transition.From)+">"+string(transition.To)+" by "+transition.Actor)
+	}
+
+	return steps
+}
+
+func createTestBooking(t *testing.T, d *Database, start time.Time, status types.BookingStatus) *types.Booking {
+	t.Helper()
+
+	booking, err := d.CreateBooking(&types.Booking{
+		ServiceID: 1,
+		Start:     start,
+		End:       start.Add(time.Hour),
+		Customer:  "Jane",
+		Status:    status,
+	}, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot create booking: %s", err)
+	}
+
+	return booking
+}
+
+func TestTransitionBooking(t *testing.T) {
+	d := testDatabase(t)
+	now := time.Now()
+	tomorrow := now.Add(24 * time.Hour).Truncate(time.Hour)
+
+	cases := []struct {
+		name      string
+		start     time.Time
+		initial   types.BookingStatus
+		to        []types.BookingStatus
+		wantErr   error
+		wantSteps []string
+	}{
+		{
+			name:      "approved",
+			start:     tomorrow,
+			initial:   types.BookingRequested,
+			to:        []types.BookingStatus{types.BookingConfirmed},
+			wantSteps: []string{">requested by staff", "requested>confirmed by staff"},
+		},
+		{
+			name:      "declined",
+			start:     tomorrow.Add(2 * time.Hour),
+			initial:   types.BookingRequested,
+			to:        []types.BookingStatus{types.BookingDeclined},
+			wantSteps: []string{">requested by staff", "requested>declined by staff"},
+		},
+		{
+			name:      "completed after the start",
+			start:     now.Add(-2 * time.Hour),
+			initial:   types.BookingConfirmed,
+			to:        []types.BookingStatus{types.BookingCompleted},
+			wantSteps: []string{">confirmed by staff", "confirmed>completed by staff"},
+		},
+		{
+			name:      "completed before the start",
+			start:     tomorrow.Add(4 * time.Hour),
+			initial:   types.BookingConfirmed,
+			to:        []types.BookingStatus{types.BookingCompleted},
+			wantErr:   ErrInvalidTransition,
+			wantSteps: []string{">confirmed by staff"},
+		},
+		{
+			name:      "declined once confirmed",
+			start:     tomorrow.Add(6 * time.Hour),
+			initial:   types.BookingConfirmed,
+			to:        []types.BookingStatus{types.BookingDeclined},
+			wantErr:   ErrInvalidTransition,
+			wantSteps: []string{">confirmed by staff"},
+		},
+		{
+			name:      "final status",
+			start:     now.Add(-4 * time.Hour),
+			initial:   types.BookingConfirmed,
+			to:        []types.BookingStatus{types.BookingNoShow, types.BookingCompleted},
+			wantErr:   ErrInvalidTransition,
+			wantSteps: []string{">confirmed by staff", "confirmed>no-show by staff"},
+		},
+		{
+			name:      "cancelled without a fee",
+			start:     tomorrow.Add(8 * time.Hour),
+			initial:   types.BookingConfirmed,
+			to:        []types.BookingStatus{types.BookingCancelled},
+			wantErr:   ErrInvalidTransition,
+			wantSteps: []string{">confirmed by staff"},
+		},
+	}
+
+	staff := d.WithActor("staff")
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			booking := createTestBooking(t, staff, tc.start, tc.initial)
+
+			var err error
+			for _, to := range tc.to {
+				if _, err = staff.TransitionBooking(booking.ID, to, now); err != nil {
+					break
+				}
+			}
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("got error %v, want %v", err, tc.wantErr)
+			}
+
+			if got := transitionSteps(t, d, booking.ID); !reflect.DeepEqual(got, tc.wantSteps) {
+				t.Errorf("got transitions %v, want %v", got, tc.wantSteps)
+			}
+		})
+	}
+}
+
+func TestCancelBooking(t *testing.T) {
+	d := testDatabase(t)
+	booking := createTestBooking(t, d.WithActor("Jane"), time.Now().Add(time.Hour), types.BookingConfirmed)
+
+	cancelled, err := d.WithActor("staff").CancelBooking(booking.ID, 12.5)
+	if err != nil {
+		t.Fatalf("cannot cancel booking: %s", err)
+	}
+
+	if cancelled.Status != types.BookingCancelled || cancelled.Fees != 12.5 {
+		t.Errorf("got status %s and fees %g, want %s and 12.5", cancelled.Status, cancelled.Fees, types.BookingCancelled)
+	}
+
+	if _, err := d.CancelBooking(booking.ID, 0); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("cancelling twice: got error %v, want %v", err, ErrInvalidTransition)
+	}
+
+	want := []string{">confirmed by Jane", "confirmed>cancelled by staff"}
+	if got := transitionSteps(t, d, booking.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("got transitions %v, want %v", got, want)
+	}
+
+	// The time of a cancelled booking can be booked again.
+	createTestBooking(t, d, booking.Start, types.BookingConfirmed)
+}
+
+func TestConfirmHoldRecordsCreation(t *testing.T) {
+	d := testDatabase(t)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	hold, err := d.CreateHold(&types.Hold{ServiceID: 1, Start: start, End: start.Add(time.Hour)}, time.Minute, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot create hold: %s", err)
+	}
+
+	booking, err := d.WithActor("Jane").ConfirmHold(hold.ID, &types.Booking{Customer: "Jane", Status: types.BookingRequested})
+	if err != nil {
+		t.Fatalf("cannot confirm hold: %s", err)
+	}
+
+	want := []string{">requested by Jane"}
+	if got := transitionSteps(t, d, booking.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("got transitions %v, want %v", got, want)
+	}
+}
+
+func TestCancelChain(t *testing.T) {
+	d := testDatabase(t)
+	start := time.Now().Add(-30 * time.Minute).Truncate(time.Minute)
+
+	chain, err := d.WithActor("Jane").CreateChain(&types.Chain{Customer: "Jane"}, []ChainSegment{
+		{ServiceID: 1, Start: start, End: start.Add(time.Hour), Status: types.BookingConfirmed},
+		{ServiceID: 2, Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), Status: types.BookingConfirmed},
+	})
+	if err != nil {
+		t.Fatalf("cannot create chain: %s", err)
+	}
+
+	started, upcoming := chain.Bookings[0], chain.Bookings[1]
+	cancelled, err := d.WithActor("staff").CancelChain(chain.ID, []Cancellation{{BookingID: upcoming.ID, Fee: 5}})
+	if err != nil {
+		t.Fatalf("cannot cancel chain: %s", err)
+	}
+
+	if len(cancelled) != 1 || cancelled[0].ID != upcoming.ID || cancelled[0].Status != types.BookingCancelled {
+		t.Fatalf("got cancelled bookings %+v, want only %d", cancelled, upcoming.ID)
+	}
+
+	kept, err := d.GetBookingByID(started.ID)
+	if err != nil {
+		t.Fatalf("the started segment was removed: %s", err)
+	}
+
+	if kept.Status != types.BookingConfirmed {
+		t.Errorf("the started segment is %s, want %s", kept.Status, types.BookingConfirmed)
+	}
+
+	want := []string{">confirmed by Jane", "confirmed>cancelled by staff"}
+	if got := transitionSteps(t, d, upcoming.ID); !reflect.DeepEqual(got, want) {
+		t.Errorf("got transitions %v, want %v", got, want)
+	}
+}
+
+func TestCancelChainAllOrNothing(t *testing.T) {
+	d := testDatabase(t)
+	start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+
+	chain, err := d.CreateChain(&types.Chain{Customer: "Jane"}, []ChainSegment{
+		{ServiceID: 1, Start: start, End: start.Add(time.Hour), Status: types.BookingConfirmed},
+	})
+	if err != nil {
+		t.Fatalf("cannot create chain: %s", err)
+	}
+
+	other := createTestBooking(t, d, start.Add(4*time.Hour), types.BookingConfirmed)
+
+	// The other booking is not of the chain, so nothing is cancelled.
+	if _, err := d.CancelChain(chain.ID, []Cancellation{
+		{BookingID: chain.Bookings[0].ID},
+		{BookingID: other.ID},
+	}); err == nil {
+		t.Fatal("cancelled a booking that is not of the chain")
+	}
+
+	for _, id := range []uint{chain.Bookings[0].ID, other.ID} {
+		booking, err := d.GetBookingByID(id)
+		if err != nil {
+			t.Fatalf("cannot get booking: %s", err)
+		}
+
+		if booking.Status != types.BookingConfirmed {
+			t.Errorf("booking %d is %s, want %s", id, booking.Status, types.BookingConfirmed)
+		}
+	}
+}
+
+func TestCancelSeries(t *testing.T) {
+	d := testDatabase(t)
+	start := time.Now().Add(-30 * time.Minute).Truncate(time.Minute)
+
+	series, err := d.WithActor("Jane").CreateSeries(&types.Series{
+		ServiceID: 1,
+		Start:     start,
+		End:       start.Add(time.Hour),
+		RRule:     "FREQ=DAILY;COUNT=3",
+		Customer:  "Jane",
+	}, []time.Time{start, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)}, types.BookingConfirmed, 0, 0)
+	if err != nil {
+		t.Fatalf("cannot create series: %s", err)
+	}
+
+	cancellations := []Cancellation{}
+	for _, occurrence := range series.Occurrences[1:] {
+		cancellations = append(cancellations, Cancellation{BookingID: occurrence.Booking.ID, Fee: 10})
+	}
+
+	cancelled, err := d.WithActor("staff").CancelSeries(series.ID, cancellations)
+	if err != nil {
+		t.Fatalf("cannot cancel series: %s", err)
+	}
+
+	if len(cancelled) != 2 {
+		t.Fatalf("got %d cancelled occurrences, want 2", len(cancelled))
+	}
+
+	for _, booking := range cancelled {
+		if booking.Status != types.BookingCancelled || booking.Fees != 10 {
+			t.Errorf("occurrence %d has status %s and fees %g, want %s and 10",
+				booking.ID, booking.Status, booking.Fees, types.BookingCancelled)
+		}
+
+		want := []string{">confirmed by Jane", "confirmed>cancelled by staff"}
+		if got := transitionSteps(t, d, booking.ID); !reflect.DeepEqual(got, want) {
+			t.Errorf("occurrence %d: got transitions %v, want %v", booking.ID, got, want)
+		}
+	}
+
+	kept, err := d.GetBookingByID(series.Occurrences[0].Booking.ID)
+	if err != nil {
+		t.Fatalf("the started occurrence was removed: %s", err)
+	}
+
+	if kept.Status != types.BookingConfirmed {
+		t.Errorf("the started occurrence is %s, want %s", kept.Status, types.BookingConfirmed)
+	}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"github.com/jackc/pgconn"
+)
+
+func checkBookingBeforePut(booking *types.Booking, bufferBefore, bufferAfter time.Duration) (*Booking, error) {
+	if booking.ServiceID == 0 {
+		return nil, fmt.Errorf("no service provided")
+	}
+
+	if booking.Start.IsZero() || booking.End.IsZero() {
+		return nil, fmt.Errorf("no start or end provided")
+	}
+
+	if !booking.End.After(booking.Start) {
+		return nil, fmt.Errorf("invalid end provided")
+	}
+
+	customer := strings.TrimSpace(booking.Customer)
+	switch l := len(customer); {
+	case l == 0:
+		return nil, fmt.Errorf("no customer provided")
+	case l > maxCustomerLength:
+		return nil, fmt.Errorf("customer too long")
+	}
+
+	if len(booking.Notes) > maxNotesLength {
+		return nil, fmt.Errorf("notes too long")
+	}
+
+	if bufferBefore < 0 || bufferAfter < 0 {
+		return nil, fmt.Errorf("invalid buffers provided")
+	}
+
+	status := booking.Status
+	if status == "" {
+		status = types.BookingConfirmed
+	}
+
+	if !status.IsValid() {
+		return nil, fmt.Errorf("invalid status provided")
+	}
+
+	return &Booking{
+		ServiceID:    booking.ServiceID,
+		StartsAt:     booking.Start,
+		EndsAt:       booking.End,
+		Customer:     customer,
+		Notes:        booking.Notes,
+		BlockedFrom:  booking.Start.Add(-bufferBefore),
+		BlockedUntil: booking.End.Add(bufferAfter),
+		Status:       status,
+	}, nil
+}
+
+// isUniqueViolation tells whether err comes from a unique constraint.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
+
+// isExclusionViolation tells whether err comes from an exclusion constraint.
+func isExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == exclusionViolationCode
+}
+
+// checkMovable returns ErrInvalidTransition if the time of the booking
+// cannot be changed anymore because of its status.
+func checkMovable(booking *types.Booking) error {
+	if booking.Status != types.BookingRequested && booking.Status != types.BookingConfirmed {
+		return fmt.Errorf("%w: cannot move a booking that is %s", ErrInvalidTransition, booking.Status)
+	}
+
+	return nil
+}
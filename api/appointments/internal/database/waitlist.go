@@ -0,0 +1,310 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoOffer is returned when answering an offer that an entry does not
+	// have.
+	ErrNoOffer = errors.New("the entry has no pending offer")
+)
+
+func (d *Database) GetWaitlistEntryByID(id uint) (*types.WaitlistEntry, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	var entry WaitlistEntry
+	if err := d.DB.Model(&WaitlistEntry{}).Where("id = ?", id).First(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	return entry.ToAPI(), nil
+}
+
+// GetWaitlist returns the entries that are waiting or have a pending offer,
+// in the order they are served. If serviceID is 0, the entries of all the
+// services are returned.
+func (d *Database) GetWaitlist(serviceID uint) ([]types.WaitlistEntry, error) {
+	query := d.DB.Order("id asc").Model(&WaitlistEntry{}).
+		Where("status IN ?", []types.WaitlistStatus{types.WaitlistWaiting, types.WaitlistOffered})
+	if serviceID != 0 {
+		query = query.Scopes(byServiceID(serviceID))
+	}
+
+	entries := []WaitlistEntry{}
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.WaitlistEntry, len(entries))
+	for i := 0; i < len(entries); i++ {
+		converted[i] = *entries[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// GetWaitingEntries returns the entries of the service that are waiting
+// for a slot that can still be offered at now, in the order they are
+// served.
+func (d *Database) GetWaitingEntries(serviceID uint, now time.Time) ([]types.WaitlistEntry, error) {
+	entries := []WaitlistEntry{}
+	if err := d.DB.Order("id asc").Model(&WaitlistEntry{}).
+		Scopes(byServiceID(serviceID), byWaitlistStatus(types.WaitlistWaiting)).
+		Where("window_to > ?", now).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.WaitlistEntry, len(entries))
+	for i := 0; i < len(entries); i++ {
+		converted[i] = *entries[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// GetWaitingServices returns the services with entries waiting for a slot
+// that can still be offered at now.
+func (d *Database) GetWaitingServices(now time.Time) ([]uint, error) {
+	ids := []uint{}
+	if err := d.DB.Model(&WaitlistEntry{}).
+		Scopes(byWaitlistStatus(types.WaitlistWaiting)).
+		Where("window_to > ?", now).
+		Distinct().Pluck("service_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+func (d *Database) CreateWaitlistEntry(entry *types.WaitlistEntry) (*types.WaitlistEntry, error) {
+	if entry == nil {
+		return nil, fmt.Errorf("no entry provided")
+	}
+
+	if entry.ServiceID == 0 {
+		return nil, fmt.Errorf("no service provided")
+	}
+
+	if entry.From.IsZero() || !entry.To.After(entry.From) {
+		return nil, fmt.Errorf("invalid from or to provided")
+	}
+
+	customer := strings.TrimSpace(entry.Customer)
+	switch l := len(customer); {
+	case l == 0:
+		return nil, fmt.Errorf("no customer provided")
+	case l > maxCustomerLength:
+		return nil, fmt.Errorf("customer too long")
+	}
+
+	if len(entry.Notes) > maxNotesLength {
+		return nil, fmt.Errorf("notes too long")
+	}
+
+	entryToCreate := &WaitlistEntry{
+		ServiceID:  entry.ServiceID,
+		WindowFrom: entry.From,
+		WindowTo:   entry.To,
+		Customer:   customer,
+		Notes:      entry.Notes,
+		Status:     types.WaitlistWaiting,
+	}
+
+	if err := d.DB.Create(entryToCreate).Error; err != nil {
+		return nil, err
+	}
+
+	return entryToCreate.ToAPI(), nil
+}
+
+// OfferSlot offers the slot taken by the hold to the entry, which must be
+// waiting: if it is not anymore, ErrChanged is returned and the hold is
+// left as it is.
+func (d *Database) OfferSlot(id uint, hold *types.Hold) (*types.WaitlistEntry, error) {
+	if hold == nil {
+		return nil, fmt.Errorf("no hold provided")
+	}
+
+	res := d.DB.Model(&WaitlistEntry{}).Where("id = ?", id).
+		Scopes(byWaitlistStatus(types.WaitlistWaiting)).
+		Updates(map[string]interface{}{
+			"status":           types.WaitlistOffered,
+			"hold_id":          hold.ID,
+			"offer_start":      hold.Start,
+			"offer_end":        hold.End,
+			"offer_expires_at": hold.ExpiresAt,
+		})
+	if res.Error != nil {
+		return nil, fmt.Errorf("cannot offer slot: %w", res.Error)
+	}
+
+	if res.RowsAffected == 0 {
+		return nil, ErrChanged
+	}
+
+	return d.GetWaitlistEntryByID(id)
+}
+
+// AcceptOffer books the slot offered to the entry for its customer, with the
+// provided status. If the offer is closed in the meantime, ErrNoOffer is
+// returned.
+func (d *Database) AcceptOffer(id uint, status types.BookingStatus) (*types.Booking, error) {
+	entry, err := d.getOffered(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var booking *types.Booking
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		// The entry is updated first: this keeps it locked, so that the offer
+		// cannot expire or be declined until the hold is confirmed as well.
+		res := tx.Model(&WaitlistEntry{}).Where("id = ? AND hold_id = ?", id, *entry.HoldID).
+			Scopes(byWaitlistStatus(types.WaitlistOffered)).
+			Updates(map[string]interface{}{
+				"status":     types.WaitlistAccepted,
+				"booking_id": *entry.HoldID,
+			})
+		if res.Error != nil {
+			return fmt.Errorf("cannot accept offer: %w", res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrNoOffer
+		}
+
+		inTx := *d
+		inTx.DB = tx
+
+		var err error
+		booking, err = inTx.ConfirmHold(*entry.HoldID, &types.Booking{
+			Customer: entry.Customer,
+			Notes:    entry.Notes,
+			Status:   status,
+		})
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+// DeclineOffer frees the slot offered to the entry, which will not be
+// offered others.
+func (d *Database) DeclineOffer(id uint) (*types.WaitlistEntry, error) {
+	entry, err := d.getOffered(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.closeOffer(entry, types.WaitlistDeclined); err != nil {
+		return nil, err
+	}
+
+	return d.GetWaitlistEntryByID(id)
+}
+
+// ExpireOffers frees the slots offered to the entries that did not answer
+// by now and returns those entries.
+func (d *Database) ExpireOffers(now time.Time) ([]types.WaitlistEntry, error) {
+	entries := []WaitlistEntry{}
+	if err := d.DB.Order("id asc").Model(&WaitlistEntry{}).
+		Scopes(byWaitlistStatus(types.WaitlistOffered)).
+		Where("offer_expires_at <= ?", now).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	expired := []types.WaitlistEntry{}
+	for i := range entries {
+		if err := d.closeOffer(&entries[i], types.WaitlistExpired); err != nil {
+			if errors.Is(err, ErrChanged) {
+				continue
+			}
+
+			return expired, err
+		}
+
+		entries[i].Status = types.WaitlistExpired
+		expired = append(expired, *entries[i].ToAPI())
+	}
+
+	return expired, nil
+}
+
+// DeleteWaitlistEntry removes the entry from the waitlist, freeing the slot
+// offered to it if any.
+func (d *Database) DeleteWaitlistEntry(id uint) error {
+	var entry WaitlistEntry
+	if err := d.DB.Model(&WaitlistEntry{}).Where("id = ?", id).First(&entry).Error; err != nil {
+		return err
+	}
+
+	if entry.Status == types.WaitlistOffered {
+		if err := d.releaseOfferHold(d.DB, &entry); err != nil {
+			return err
+		}
+	}
+
+	if err := d.DB.Where("id = ?", id).Delete(&WaitlistEntry{}).Error; err != nil {
+		return fmt.Errorf("cannot delete entry: %w", err)
+	}
+
+	return nil
+}
+
+// getOffered returns the entry, which must have a pending offer.
+func (d *Database) getOffered(id uint) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	if err := d.DB.Model(&WaitlistEntry{}).Where("id = ?", id).First(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	if entry.Status != types.WaitlistOffered || entry.HoldID == nil {
+		return nil, ErrNoOffer
+	}
+
+	return &entry, nil
+}
+
+// closeOffer sets the status of the entry, which must have a pending offer,
+// and frees the slot that was offered.
+func (d *Database) closeOffer(entry *WaitlistEntry, status types.WaitlistStatus) error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&WaitlistEntry{}).Where("id = ?", entry.ID).
+			Scopes(byWaitlistStatus(types.WaitlistOffered)).
+			Update("status", status)
+		if res.Error != nil {
+			return fmt.Errorf("cannot close offer: %w", res.Error)
+		}
+
+		if res.RowsAffected == 0 {
+			return ErrChanged
+		}
+
+		return d.releaseOfferHold(tx, entry)
+	})
+}
+
+// releaseOfferHold deletes the hold of the offer made to the entry, unless
+// it was confirmed or already deleted.
+func (d *Database) releaseOfferHold(tx *gorm.DB, entry *WaitlistEntry) error {
+	if entry.HoldID == nil {
+		return nil
+	}
+
+	if err := tx.Unscoped().Scopes(byBookingID(*entry.HoldID), held()).
+		Delete(&Booking{}).Error; err != nil {
+		return fmt.Errorf("cannot release hold: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package remote contains the clients of the other APIs of appoint.
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when the requested resource does not exist.
+	ErrNotFound = errors.New("not found")
+)
+
+// getJSON sends a GET request to url and decodes the JSON response into
+// out.
+func getJSON(client *http.Client, url string, out interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("cannot get %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("cannot get %s: %s: %s", url, res.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("cannot decode response of %s: %w", url, err)
+	}
+
+	return nil
+}
+
+// postJSON sends out, encoded as JSON, to url.
+func postJSON(client *http.Client, url string, out interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	body, err := json.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("cannot encode request to %s: %w", url, err)
+	}
+
+	res, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot post to %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("cannot post to %s: %s: %s", url, res.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	servicestypes "github.com/asimpleidea/appoint/api/services/pkg/types"
+)
+
+// Services is a client of the services API.
+type Services struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *Services) GetService(id uint) (*servicestypes.Service, error) {
+	var service servicestypes.Service
+	if err := getJSON(s.Client, fmt.Sprintf("%s/services/%d", strings.TrimSuffix(s.URL, "/"), id), &service); err != nil {
+		return nil, err
+	}
+
+	return &service, nil
+}
+
+// GetPolicy returns the policy in effect for the service, which may come
+// from one of its parents. ErrNotFound is returned if none has a policy.
+func (s *Services) GetPolicy(id uint) (*servicestypes.EffectivePolicy, error) {
+	var policy servicestypes.EffectivePolicy
+	if err := getJSON(s.Client, fmt.Sprintf("%s/services/%d/policy", strings.TrimSuffix(s.URL, "/"), id), &policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
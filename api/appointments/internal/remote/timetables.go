@@ -0,0 +1,85 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/availability"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/schedule"
+	timetablestypes "github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const (
+	// maxServiceDepth is how many parents are visited looking for a
+	// timetable, to stop on cycles.
+	maxServiceDepth int = 16
+)
+
+var (
+	// ErrNoTimetable is returned when neither a service nor its parents
+	// have a timetable in effect.
+	ErrNoTimetable = errors.New("no timetable in effect for the service")
+)
+
+// Timetables is a client of the timetables API.
+type Timetables struct {
+	URL    string
+	Client *http.Client
+}
+
+// Resolve returns, in full, the timetable of the owner in effect on the day
+// of date.
+func (t *Timetables) Resolve(ownerType timetablestypes.OwnerType, ownerID uint, date time.Time) (*timetablestypes.Timetable, error) {
+	var tt timetablestypes.Timetable
+	if err := getJSON(t.Client, fmt.Sprintf("%s/timetables/owners/%s/%d/resolve?date=%s",
+		strings.TrimSuffix(t.URL, "/"), ownerType, ownerID, date.Format(schedule.DateFormat)), &tt); err != nil {
+		return nil, err
+	}
+
+	return &tt, nil
+}
+
+// EffectiveTimetable returns the timetable in effect for the service on the
+// day of date: the one of the service or, if it has none, the one of the
+// closest parent that has one.
+func EffectiveTimetable(services *Services, timetables *Timetables, serviceID uint, date time.Time) (*timetablestypes.Timetable, error) {
+	id := serviceID
+	for depth := 0; depth < maxServiceDepth; depth++ {
+		tt, err := timetables.Resolve(timetablestypes.OwnerService, id, date)
+		if err == nil {
+			return tt, nil
+		}
+
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+
+		service, err := services.GetService(id)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get service %d: %w", id, err)
+		}
+
+		if service.ParentID == nil {
+			break
+		}
+		id = *service.ParentID
+	}
+
+	return nil, ErrNoTimetable
+}
+
+// ServiceTimetables returns a resolver of the timetables in effect for the
+// service, as EffectiveTimetable does.
+func ServiceTimetables(services *Services, timetables *Timetables, serviceID uint) availability.Resolver {
+	return func(date time.Time) (*timetablestypes.Timetable, error) {
+		tt, err := EffectiveTimetable(services, timetables, serviceID, date)
+		if errors.Is(err, ErrNoTimetable) {
+			return nil, nil
+		}
+
+		return tt, err
+	}
+}
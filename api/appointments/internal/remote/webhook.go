@@ -0,0 +1,17 @@
+package remote
+
+import (
+	"net/http"
+
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+)
+
+// Webhook posts waitlist events to URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *Webhook) Notify(event *types.WaitlistEvent) error {
+	return postJSON(w.Client, w.URL, event)
+}
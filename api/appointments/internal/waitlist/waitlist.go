@@ -0,0 +1,204 @@
+// Package waitlist offers freed slots to the customers waiting for them.
+package waitlist
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/internal/database"
+	"github.com/asimpleidea/appoint/api/appointments/internal/remote"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/availability"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/rs/zerolog"
+)
+
+// Notifier is told when an offer is made to an entry or expires.
+type Notifier interface {
+	Notify(event *types.WaitlistEvent) error
+}
+
+// Store keeps the waitlists and the time taken by bookings and holds, as
+// *database.Database does.
+type Store interface {
+	GetWaitingEntries(serviceID uint, now time.Time) ([]types.WaitlistEntry, error)
+	GetWaitingServices(now time.Time) ([]uint, error)
+	GetBusy(serviceID uint, start, end time.Time) ([]interval.Interval, error)
+	CreateHold(hold *types.Hold, ttl, bufferBefore, bufferAfter time.Duration) (*types.Hold, error)
+	ReleaseHold(id uint) error
+	OfferSlot(id uint, hold *types.Hold) (*types.WaitlistEntry, error)
+	ExpireOffers(now time.Time) ([]types.WaitlistEntry, error)
+}
+
+// Promoter offers the free slots of services to their waitlists, in the
+// order the entries were created.
+type Promoter struct {
+	DB         Store
+	Services   *remote.Services
+	Timetables *remote.Timetables
+	// Location is the one of the days of the timetables.
+	Location *time.Location
+	// OfferTTL is how long entries have to accept an offer.
+	OfferTTL time.Duration
+	// Notifier is optional.
+	Notifier Notifier
+	Logger   zerolog.Logger
+}
+
+// Promote offers a free slot to each entry of the service that waits for
+// one, if there is one inside its window.
+func (p *Promoter) Promote(serviceID uint) error {
+	now := time.Now()
+	entries, err := p.DB.GetWaitingEntries(serviceID, now)
+	if err != nil {
+		return fmt.Errorf("cannot get waitlist: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	service, err := p.Services.GetService(serviceID)
+	if err != nil {
+		return fmt.Errorf("cannot get service %d: %w", serviceID, err)
+	}
+
+	// Classes are booked by seat, not by slot.
+	if service.Duration == 0 || service.Capacity > 1 {
+		return nil
+	}
+
+	req := availability.Request{
+		Duration:     time.Duration(service.Duration) * time.Minute,
+		BufferBefore: time.Duration(service.BufferBefore) * time.Minute,
+		BufferAfter:  time.Duration(service.BufferAfter) * time.Minute,
+	}
+
+	resolve := remote.ServiceTimetables(p.Services, p.Timetables, serviceID)
+	for _, entry := range entries {
+		from := entry.From
+		if from.Before(now) {
+			from = now
+		}
+
+		req.Busy, err = p.DB.GetBusy(serviceID, from.AddDate(0, 0, -1), entry.To.AddDate(0, 0, 1))
+		if err != nil {
+			return fmt.Errorf("cannot get busy times: %w", err)
+		}
+
+		req.From, req.To = from, entry.To
+		slots, err := availability.SlotsByDay(req, p.Location, resolve)
+		if err != nil {
+			return fmt.Errorf("cannot get slots: %w", err)
+		}
+
+		for _, slot := range slots {
+			if slot.End.After(entry.To) {
+				break
+			}
+
+			served, err := p.offer(&entry, slot, req.BufferBefore, req.BufferAfter)
+			if err != nil {
+				return err
+			}
+
+			if served {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// PromoteAll runs Promote on every service with a waitlist.
+func (p *Promoter) PromoteAll() error {
+	ids, err := p.DB.GetWaitingServices(time.Now())
+	if err != nil {
+		return fmt.Errorf("cannot get services with a waitlist: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := p.Promote(id); err != nil {
+			p.Logger.Err(err).Uint("service", id).Msg("could not promote waitlist")
+		}
+	}
+
+	return nil
+}
+
+// PromoteLater runs Promote in the background, e.g. after a slot is freed.
+func (p *Promoter) PromoteLater(serviceID uint) {
+	go func() {
+		if err := p.Promote(serviceID); err != nil {
+			p.Logger.Err(err).Uint("service", serviceID).Msg("could not promote waitlist")
+		}
+	}()
+}
+
+// Expire frees the slots offered to the entries that did not accept them by
+// now, notifies about them and offers the slots to the next entries.
+func (p *Promoter) Expire(now time.Time) error {
+	expired, err := p.DB.ExpireOffers(now)
+	for i := range expired {
+		p.notify(types.WaitlistOfferExpired, &expired[i])
+	}
+
+	if err != nil {
+		return fmt.Errorf("cannot expire offers: %w", err)
+	}
+
+	return nil
+}
+
+// offer holds the slot for the entry and offers it. It tells whether the
+// entry is served, i.e. it got the offer or is not waiting anymore: if the
+// slot was taken in the meantime, it is not.
+func (p *Promoter) offer(entry *types.WaitlistEntry, slot availability.Slot, bufferBefore, bufferAfter time.Duration) (bool, error) {
+	hold, err := p.DB.CreateHold(&types.Hold{
+		ServiceID: entry.ServiceID,
+		Start:     slot.Start,
+		End:       slot.End,
+	}, p.OfferTTL, bufferBefore, bufferAfter)
+	if err != nil {
+		if errors.Is(err, database.ErrConflict) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("cannot hold slot: %w", err)
+	}
+
+	offered, err := p.DB.OfferSlot(entry.ID, hold)
+	if err != nil {
+		if releaseErr := p.DB.ReleaseHold(hold.ID); releaseErr != nil {
+			p.Logger.Err(releaseErr).Uint("hold", hold.ID).Msg("could not release hold")
+		}
+
+		if errors.Is(err, database.ErrChanged) {
+			// The entry was served in the meantime: the slot is free for
+			// the next one.
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	p.notify(types.WaitlistOfferMade, offered)
+	return true, nil
+}
+
+func (p *Promoter) notify(eventType types.WaitlistEventType, entry *types.WaitlistEntry) {
+	if p.Notifier == nil {
+		return
+	}
+
+	if err := p.Notifier.Notify(&types.WaitlistEvent{
+		Type:  eventType,
+		At:    time.Now(),
+		Entry: entry,
+	}); err != nil {
+		p.Logger.Err(err).Uint("entry", entry.ID).Str("type", string(eventType)).
+			Msg("could not notify waitlist event")
+	}
+}
@@ -0,0 +1,419 @@
+package waitlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/internal/database"
+	"github.com/asimpleidea/appoint/api/appointments/internal/remote"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	servicestypes "github.com/asimpleidea/appoint/api/services/pkg/types"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	timetablestypes "github.com/asimpleidea/appoint/api/timetables/pkg/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	slotFormat string = "2006-01-02 15:04"
+
+	// slotServiceID lasts an hour and classServiceID is a class.
+	slotServiceID  uint = 1
+	classServiceID uint = 2
+)
+
+var rome = mustLoadLocation("Europe/Rome")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+// fakeStore keeps the entries and the holds in memory, as the database
+// does.
+type fakeStore struct {
+	entries []types.WaitlistEntry
+	holds   []types.Hold
+	// taken are the starts of the slots taken in the meantime, which cannot
+	// be held even if they are not busy.
+	taken map[string]bool
+	// served are the entries that stop waiting before an offer is made.
+	served   map[uint]bool
+	offers   map[uint]*types.Hold
+	released []uint
+
+	expired   []types.WaitlistEntry
+	expireErr error
+}
+
+func (s *fakeStore) GetWaitingEntries(serviceID uint, now time.Time) ([]types.WaitlistEntry, error) {
+	waiting := []types.WaitlistEntry{}
+	for _, entry := range s.entries {
+		if entry.ServiceID == serviceID && entry.To.After(now) {
+			waiting = append(waiting, entry)
+		}
+	}
+
+	return waiting, nil
+}
+
+func (s *fakeStore) GetWaitingServices(now time.Time) ([]uint, error) {
+	ids := []uint{}
+	seen := map[uint]bool{}
+	for _, entry := range s.entries {
+		if !seen[entry.ServiceID] && entry.To.After(now) {
+			seen[entry.ServiceID] = true
+			ids = append(ids, entry.ServiceID)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *fakeStore) GetBusy(serviceID uint, start, end time.Time) ([]interval.Interval, error) {
+	busy := []interval.Interval{}
+	for _, hold := range s.holds {
+		if hold.ServiceID == serviceID {
+			busy = append(busy, interval.Interval{Start: hold.Start, End: hold.End})
+		}
+	}
+
+	return busy, nil
+}
+
+func (s *fakeStore) CreateHold(hold *types.Hold, ttl, bufferBefore, bufferAfter time.Duration) (*types.Hold, error) {
+	if s.taken[hold.Start.In(rome).Format(slotFormat)] {
+		return nil, &database.ConflictError{Booking: &types.Booking{Start: hold.Start, End: hold.End}}
+	}
+
+	created := *hold
+	created.ID = uint(len(s.holds) + 1)
+	s.holds = append(s.holds, created)
+	return &created, nil
+}
+
+func (s *fakeStore) ReleaseHold(id uint) error {
+	for i, hold := range s.holds {
+		if hold.ID == id {
+			s.holds = append(s.holds[:i], s.holds[i+1:]...)
+			s.released = append(s.released, id)
+			return nil
+		}
+	}
+
+	return errors.New("hold not found")
+}
+
+func (s *fakeStore) OfferSlot(id uint, hold *types.Hold) (*types.WaitlistEntry, error) {
+	if s.served[id] {
+		return nil, database.ErrChanged
+	}
+
+	for i, entry := range s.entries {
+		if entry.ID == id {
+			s.offers[id] = hold
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			entry.Status = types.WaitlistOffered
+			return &entry, nil
+		}
+	}
+
+	return nil, errors.New("entry not found")
+}
+
+func (s *fakeStore) ExpireOffers(now time.Time) ([]types.WaitlistEntry, error) {
+	return s.expired, s.expireErr
+}
+
+// offered returns the start of the slot offered to each entry.
+func (s *fakeStore) offered() map[uint]string {
+	offered := map[uint]string{}
+	for id, hold := range s.offers {
+		offered[id] = hold.Start.In(rome).Format(slotFormat)
+	}
+
+	return offered
+}
+
+type recordingNotifier struct {
+	events []types.WaitlistEvent
+}
+
+func (n *recordingNotifier) Notify(event *types.WaitlistEvent) error {
+	n.events = append(n.events, *event)
+	return nil
+}
+
+// eventEntries returns the type and the entry of each event.
+func (n *recordingNotifier) eventEntries() []string {
+	var formatted []string
+	for _, event := range n.events {
+		formatted = append(formatted, fmt.Sprintf("%s %d", event.Type, event.Entry.ID))
+	}
+
+	return formatted
+}
+
+// newRemotes returns the services and timetables APIs, where both the
+// services are open every day from 09:00 to 12:00.
+func newRemotes(t *testing.T) (*remote.Services, *remote.Timetables) {
+	services := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services/1":
+			json.NewEncoder(w).Encode(servicestypes.Service{ID: slotServiceID, Duration: 60})
+		case "/services/2":
+			json.NewEncoder(w).Encode(servicestypes.Service{ID: classServiceID, Duration: 60, Capacity: 10})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(services.Close)
+
+	morning := func(dow timetablestypes.DOW) []timetablestypes.TimetableDay {
+		return []timetablestypes.TimetableDay{
+			{DayOfWeek: dow, Opening: "09:00", Closing: "12:00", Kind: timetablestypes.KindOpen},
+		}
+	}
+	tt := &timetablestypes.Timetable{
+		ValidFrom:     time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Timezone:      "Europe/Rome",
+		RotationWeeks: 1,
+		Monday:        morning(timetablestypes.Monday),
+		Tuesday:       morning(timetablestypes.Tuesday),
+		Wednesday:     morning(timetablestypes.Wednesday),
+		Thursday:      morning(timetablestypes.Thursday),
+		Friday:        morning(timetablestypes.Friday),
+		Saturday:      morning(timetablestypes.Saturday),
+		Sunday:        morning(timetablestypes.Sunday),
+	}
+
+	timetables := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/resolve") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(tt)
+	}))
+	t.Cleanup(timetables.Close)
+
+	return &remote.Services{URL: services.URL, Client: services.Client()},
+		&remote.Timetables{URL: timetables.URL, Client: timetables.Client()}
+}
+
+func newPromoter(t *testing.T, store *fakeStore) (*Promoter, *recordingNotifier) {
+	if store.offers == nil {
+		store.offers = map[uint]*types.Hold{}
+	}
+
+	services, timetables := newRemotes(t)
+	notifier := &recordingNotifier{}
+	return &Promoter{
+		DB:         store,
+		Services:   services,
+		Timetables: timetables,
+		Location:   rome,
+		OfferTTL:   30 * time.Minute,
+		Notifier:   notifier,
+		Logger:     zerolog.Nop(),
+	}, notifier
+}
+
+// tomorrow returns the time of tomorrow in Rome, parsed as "15:04", and
+// the same time formatted as the offered slots are.
+func tomorrow(clock string) (time.Time, string) {
+	now := time.Now().In(rome)
+	parsed, err := time.ParseInLocation("15:04", clock, rome)
+	if err != nil {
+		panic(err)
+	}
+
+	at := time.Date(now.Year(), now.Month(), now.Day()+1, parsed.Hour(), parsed.Minute(), 0, 0, rome)
+	return at, at.Format(slotFormat)
+}
+
+// waitingTomorrow returns an entry of the service waiting for a slot
+// between from and to of tomorrow.
+func waitingTomorrow(id, serviceID uint, from, to string) types.WaitlistEntry {
+	start, _ := tomorrow(from)
+	end, _ := tomorrow(to)
+	return types.WaitlistEntry{
+		ID:        id,
+		ServiceID: serviceID,
+		From:      start,
+		To:        end,
+		Status:    types.WaitlistWaiting,
+	}
+}
+
+func TestPromote(t *testing.T) {
+	_, nine := tomorrow("09:00")
+	_, nineFifteen := tomorrow("09:15")
+	_, ten := tomorrow("10:00")
+
+	cases := []struct {
+		name         string
+		store        *fakeStore
+		serviceID    uint
+		wantOffered  map[uint]string
+		wantReleased []uint
+		wantEvents   []string
+	}{
+		{
+			name: "in the order the entries were created",
+			store: &fakeStore{entries: []types.WaitlistEntry{
+				waitingTomorrow(1, slotServiceID, "00:00", "23:59"),
+				waitingTomorrow(2, slotServiceID, "00:00", "23:59"),
+			}},
+			serviceID:   slotServiceID,
+			wantOffered: map[uint]string{1: nine, 2: ten},
+			wantEvents:  []string{"offer.made 1", "offer.made 2"},
+		},
+		{
+			name: "inside the window of the entry",
+			store: &fakeStore{entries: []types.WaitlistEntry{
+				waitingTomorrow(1, slotServiceID, "09:50", "23:59"),
+			}},
+			serviceID:   slotServiceID,
+			wantOffered: map[uint]string{1: ten},
+			wantEvents:  []string{"offer.made 1"},
+		},
+		{
+			name: "no slot ends inside the window",
+			store: &fakeStore{entries: []types.WaitlistEntry{
+				waitingTomorrow(1, slotServiceID, "09:00", "09:30"),
+			}},
+			serviceID:   slotServiceID,
+			wantOffered: map[uint]string{},
+		},
+		{
+			name: "slot taken in the meantime",
+			store: &fakeStore{
+				entries: []types.WaitlistEntry{
+					waitingTomorrow(1, slotServiceID, "00:00", "23:59"),
+				},
+				taken: map[string]bool{nine: true},
+			},
+			serviceID:   slotServiceID,
+			wantOffered: map[uint]string{1: nineFifteen},
+			wantEvents:  []string{"offer.made 1"},
+		},
+		{
+			name: "entry served in the meantime",
+			store: &fakeStore{
+				entries: []types.WaitlistEntry{
+					waitingTomorrow(1, slotServiceID, "00:00", "23:59"),
+					waitingTomorrow(2, slotServiceID, "00:00", "23:59"),
+				},
+				served: map[uint]bool{1: true},
+			},
+			serviceID:    slotServiceID,
+			wantOffered:  map[uint]string{2: nine},
+			wantReleased: []uint{1},
+			wantEvents:   []string{"offer.made 2"},
+		},
+		{
+			name: "classes are not offered slots",
+			store: &fakeStore{entries: []types.WaitlistEntry{
+				waitingTomorrow(1, classServiceID, "00:00", "23:59"),
+			}},
+			serviceID:   classServiceID,
+			wantOffered: map[uint]string{},
+		},
+		{
+			name:        "nobody waiting",
+			store:       &fakeStore{},
+			serviceID:   slotServiceID,
+			wantOffered: map[uint]string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			promoter, notifier := newPromoter(t, tc.store)
+			if err := promoter.Promote(tc.serviceID); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := tc.store.offered(); !reflect.DeepEqual(got, tc.wantOffered) {
+				t.Errorf("got offers %v, want %v", got, tc.wantOffered)
+			}
+
+			if !reflect.DeepEqual(tc.store.released, tc.wantReleased) {
+				t.Errorf("got released holds %v, want %v", tc.store.released, tc.wantReleased)
+			}
+
+			if got := notifier.eventEntries(); !reflect.DeepEqual(got, tc.wantEvents) {
+				t.Errorf("got events %v, want %v", got, tc.wantEvents)
+			}
+		})
+	}
+}
+
+func TestPromoteAll(t *testing.T) {
+	_, nine := tomorrow("09:00")
+
+	store := &fakeStore{entries: []types.WaitlistEntry{
+		waitingTomorrow(1, slotServiceID, "00:00", "23:59"),
+		waitingTomorrow(2, classServiceID, "00:00", "23:59"),
+	}}
+	promoter, _ := newPromoter(t, store)
+
+	if err := promoter.PromoteAll(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := map[uint]string{1: nine}
+	if got := store.offered(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got offers %v, want %v", got, want)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	expired := []types.WaitlistEntry{
+		{ID: 1, ServiceID: slotServiceID, Status: types.WaitlistExpired},
+		{ID: 2, ServiceID: slotServiceID, Status: types.WaitlistExpired},
+	}
+
+	cases := []struct {
+		name    string
+		store   *fakeStore
+		wantErr bool
+	}{
+		{
+			name:  "notifies every expired offer",
+			store: &fakeStore{expired: expired},
+		},
+		{
+			name:    "notifies the offers expired before an error",
+			store:   &fakeStore{expired: expired, expireErr: errors.New("connection lost")},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			promoter, notifier := newPromoter(t, tc.store)
+
+			err := promoter.Expire(time.Now())
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want one: %t", err, tc.wantErr)
+			}
+
+			want := []string{"offer.expired 1", "offer.expired 2"}
+			if got := notifier.eventEntries(); !reflect.DeepEqual(got, want) {
+				t.Errorf("got events %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1963 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/internal/database"
+	"github.com/asimpleidea/appoint/api/appointments/internal/remote"
+	"github.com/asimpleidea/appoint/api/appointments/internal/waitlist"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/availability"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/policy"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	coredb "github.com/asimpleidea/appoint/api/core/pkg/database"
+	"github.com/asimpleidea/appoint/api/core/pkg/ical"
+	"github.com/asimpleidea/appoint/api/core/pkg/text"
+	servicestypes "github.com/asimpleidea/appoint/api/services/pkg/types"
+)
+
+const (
+	fiberAppName string = "Appointments API server"
+
+	defaultListDays int = 30
+
+	defaultAvailabilityDays int = 7
+	maxAvailabilityDays     int = 31
+	minGranularityMinutes   int = 5
+
+	maxHoldTTL time.Duration = time.Hour
+
+	maxSeriesYears       int = 2
+	maxSeriesOccurrences int = 104
+
+	maxChainServices int = 5
+
+	actorHeader    string = "X-Actor"
+	maxActorLength int    = 100
+)
+
+var (
+	log zerolog.Logger
+
+	errInvalidBooking   = errors.New("invalid booking")
+	errOutsideTimetable = errors.New("the slot is not inside the timetable of the service")
+	errClassService     = errors.New("the service is a class: book a seat of one of its sessions instead")
+)
+
+func main() {
+	dbOpts := &coredb.Options{}
+	verbosity := 1
+	var (
+		ops               *database.Database
+		servicesURL       string
+		timetablesURL     string
+		holdTTL           time.Duration
+		holdSweepInterval time.Duration
+		offerTTL          time.Duration
+		webhookURL        string
+	)
+
+	// -----------------------------------------
+	// CLI Flags
+	// -----------------------------------------
+
+	flag.IntVar(&verbosity, "verbosity", 1, "the verbosity level.")
+	flag.StringVar(&servicesURL, "services.url", "http://services:8080",
+		"the URL of the services API.")
+	flag.StringVar(&timetablesURL, "timetables.url", "http://timetables:8080",
+		"the URL of the timetables API.")
+	flag.DurationVar(&holdTTL, "holds.ttl", 10*time.Minute,
+		"how long holds last, if not provided when creating them.")
+	flag.DurationVar(&holdSweepInterval, "holds.sweep-interval", time.Minute,
+		"how often expired holds and offers are deleted and freed slots are offered to waitlists.")
+	flag.DurationVar(&offerTTL, "waitlist.offer-ttl", 30*time.Minute,
+		"how long waitlist entries have to accept the slots offered to them.")
+	flag.StringVar(&webhookURL, "waitlist.webhook-url", "",
+		"the URL where waitlist offers and their expirations are posted, if any.")
+
+	// TODO: service names in CLI flags are temporary
+	flag.StringVar(&dbOpts.Host, "database.host", "localhost",
+		"the main database where to connect to.")
+	flag.IntVar(&dbOpts.Port, "database.port", 5432,
+		"the port to use to connect to the database.")
+	flag.StringVar(&dbOpts.User, "database.user", "postgres",
+		"the user to use to authenticate to the database.")
+	flag.StringVar(&dbOpts.Password, "database.password", "",
+		"the password to use to authenticate to the database.")
+	flag.StringVar(&dbOpts.Name, "database.name", "appointments",
+		"the name of the database to use.")
+	flag.BoolVar(&dbOpts.SSLMode, "database.sslmode", true,
+		"whether to use SSL mode.")
+	flag.StringVar(&dbOpts.Timezone, "database.timezone", "Europe/Rome",
+		"the timezone to use for dates.")
+	flag.Parse()
+
+	// -----------------------------------------
+	// Set the logger
+	// -----------------------------------------
+
+	log = zerolog.New(os.Stderr).With().Logger()
+	log.Info().Int("verbosity", verbosity).Msg("starting...")
+
+	{
+		logLevels := [4]zerolog.Level{zerolog.DebugLevel, zerolog.InfoLevel, zerolog.ErrorLevel}
+		log = log.Level(logLevels[verbosity])
+	}
+
+	// -----------------------------------------
+	// Connect to the database
+	// -----------------------------------------
+
+	loc, err := time.LoadLocation(dbOpts.Timezone)
+	if err != nil {
+		log.Fatal().Err(err).Str("timezone", dbOpts.Timezone).
+			Msg("invalid timezone provided, exiting...")
+		return
+	}
+
+	dsn := coredb.GeneratePostgresDSN(*dbOpts)
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not establish connection to the database, exiting...")
+		return
+	}
+	ops = &database.Database{DB: db, Logger: log}
+	log.Debug().Msg("connected to the database")
+
+	if err := ops.Migrate(); err != nil {
+		log.Fatal().Err(err).Msg("could not migrate the database, exiting...")
+		return
+	}
+
+	if holdTTL <= 0 || holdTTL > maxHoldTTL {
+		log.Fatal().Dur("holds.ttl", holdTTL).Msg("invalid holds ttl provided, exiting...")
+		return
+	}
+
+	if holdSweepInterval <= 0 {
+		log.Fatal().Dur("holds.sweep-interval", holdSweepInterval).
+			Msg("invalid holds sweep interval provided, exiting...")
+		return
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	services := &remote.Services{URL: servicesURL, Client: httpClient}
+	timetables := &remote.Timetables{URL: timetablesURL, Client: httpClient}
+
+	if offerTTL <= 0 || offerTTL > maxHoldTTL {
+		log.Fatal().Dur("waitlist.offer-ttl", offerTTL).Msg("invalid offer ttl provided, exiting...")
+		return
+	}
+
+	promoter := &waitlist.Promoter{
+		DB:         ops,
+		Services:   services,
+		Timetables: timetables,
+		Location:   loc,
+		OfferTTL:   offerTTL,
+		Logger:     log,
+	}
+	if webhookURL != "" {
+		promoter.Notifier = &remote.Webhook{URL: webhookURL, Client: httpClient}
+	}
+
+	// -----------------------------------------
+	// Start the REST API server
+	// -----------------------------------------
+
+	app := fiber.New(fiber.Config{
+		AppName:               fiberAppName,
+		ReadTimeout:           time.Minute,
+		DisableStartupMessage: verbosity > 0,
+	})
+	app.Use(recover.New())
+
+	app.Get("/availability", func(c *fiber.Ctx) error {
+		serviceID, err := strconv.ParseUint(c.Query("service"), 10, 0)
+		if err != nil || serviceID == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid service provided"))
+		}
+
+		start, end, granularity, err := getAvailabilityQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		service, err := services.GetService(uint(serviceID))
+		if err != nil {
+			return sendSlotError(c, fmt.Errorf("cannot get service %d: %w", serviceID, err))
+		}
+
+		if service.Duration == 0 {
+			return c.Status(fiber.StatusUnprocessableEntity).
+				Send([]byte("the service has no duration"))
+		}
+
+		req := availability.Request{
+			Duration:     time.Duration(service.Duration) * time.Minute,
+			BufferBefore: time.Duration(service.BufferBefore) * time.Minute,
+			BufferAfter:  time.Duration(service.BufferAfter) * time.Minute,
+			Granularity:  granularity,
+		}
+
+		// Bookings and holds that end or start a day away can still take
+		// time with their buffers.
+		req.Busy, err = ops.GetBusy(service.ID, start.AddDate(0, 0, -1), end.AddDate(0, 0, 1))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		req.From, req.To = start, end
+		slots, err := availability.SlotsByDay(req, loc, remote.ServiceTimetables(services, timetables, service.ID))
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		return c.JSON(slots)
+	})
+
+	bookings := app.Group("/bookings")
+
+	bookings.Get("/", func(c *fiber.Ctx) error {
+		var serviceID uint
+		if c.Query("service") != "" {
+			id, err := strconv.ParseUint(c.Query("service"), 10, 0)
+			if err != nil || id == 0 {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid service provided"))
+			}
+
+			serviceID = uint(id)
+		}
+
+		start := time.Now()
+		if c.Query("from") != "" {
+			from, err := time.Parse(time.RFC3339, c.Query("from"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid from provided"))
+			}
+
+			start = from
+		}
+
+		end := start.AddDate(0, 0, defaultListDays)
+		if c.Query("to") != "" {
+			to, err := time.Parse(time.RFC3339, c.Query("to"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid to provided"))
+			}
+
+			end = to
+		}
+
+		res, err := ops.GetBookings(serviceID, start, end)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	bookings.Get("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		booking, err := ops.GetBookingByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(booking)
+	})
+
+	bookings.Post("/", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no booking provided"))
+		}
+
+		var newBooking *types.Booking
+		if err := json.Unmarshal(c.Body(), &newBooking); err != nil || newBooking == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid booking provided"))
+		}
+
+		service, err := checkSlot(services, timetables, loc, newBooking)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if isClass(service) {
+			return sendSlotError(c, errClassService)
+		}
+
+		createdBooking, err := ops.WithActor(getActor(c)).CreateBooking(&types.Booking{
+			ServiceID: newBooking.ServiceID,
+			Start:     newBooking.Start,
+			End:       newBooking.End,
+			Customer:  newBooking.Customer,
+			Notes:     newBooking.Notes,
+			Status:    initialStatus(service),
+		}, time.Duration(service.BufferBefore)*time.Minute, time.Duration(service.BufferAfter)*time.Minute)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(createdBooking)
+	})
+
+	bookings.Put("/:id", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no booking provided"))
+		}
+
+		var bookingToUpdate *types.Booking
+		if err := json.Unmarshal(c.Body(), &bookingToUpdate); err != nil || bookingToUpdate == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid booking provided"))
+		}
+
+		existingBooking, err := ops.GetBookingByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		// Moving a booking is subject to the reschedule policy of its
+		// service, so the time can only be repeated as it is.
+		if (!bookingToUpdate.Start.IsZero() && !bookingToUpdate.Start.Equal(existingBooking.Start)) ||
+			(!bookingToUpdate.End.IsZero() && !bookingToUpdate.End.Equal(existingBooking.End)) {
+			return c.Status(fiber.StatusConflict).
+				Send([]byte("the time of a booking can only be changed by rescheduling it"))
+		}
+
+		res, err := ops.UpdateBooking(&types.Booking{
+			ID:        existingBooking.ID,
+			ServiceID: bookingToUpdate.ServiceID,
+			Customer:  bookingToUpdate.Customer,
+			Notes:     bookingToUpdate.Notes,
+		})
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		return c.JSON(res)
+	})
+
+	// Deleting a booking cancels it, as the cancellation policy allows.
+	cancelBooking := func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		booking, err := ops.GetBookingByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		outcome, allowed, err := cancelPolicy(services, booking, time.Now())
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if !allowed {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(outcome)
+		}
+
+		if outcome.Booking, err = ops.WithActor(getActor(c)).CancelBooking(id, outcome.Fee); err != nil {
+			return sendBookingError(c, err)
+		}
+
+		promoter.PromoteLater(booking.ServiceID)
+		return c.JSON(outcome)
+	}
+
+	bookings.Post("/:id/cancel", cancelBooking)
+
+	bookings.Post("/:id/reschedule", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no booking provided"))
+		}
+
+		var moved *types.Booking
+		if err := json.Unmarshal(c.Body(), &moved); err != nil || moved == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid booking provided"))
+		}
+
+		booking, err := ops.GetBookingByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		service, err := checkSlot(services, timetables, loc, &types.Booking{
+			ServiceID: booking.ServiceID,
+			Start:     moved.Start,
+			End:       moved.End,
+		})
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		outcome, allowed, err := reschedulePolicy(services, booking, time.Now())
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if !allowed {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(outcome)
+		}
+
+		if outcome.Booking, err = ops.RescheduleBooking(&types.Booking{
+			ID:    booking.ID,
+			Start: moved.Start,
+			End:   moved.End,
+		}, booking.Reschedules, outcome.Fee,
+			time.Duration(service.BufferBefore)*time.Minute, time.Duration(service.BufferAfter)*time.Minute); err != nil {
+			return sendBookingError(c, err)
+		}
+
+		promoter.PromoteLater(booking.ServiceID)
+		return c.JSON(outcome)
+	})
+
+	bookings.Delete("/:id", cancelBooking)
+
+	bookings.Put("/:id/status", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no status provided"))
+		}
+
+		var change struct {
+			Status types.BookingStatus `json:"status"`
+		}
+		if err := json.Unmarshal(c.Body(), &change); err != nil || !change.Status.IsValid() {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid status provided"))
+		}
+
+		// Cancelling can cost a fee, which only the cancel endpoint charges.
+		if change.Status == types.BookingCancelled {
+			return c.Status(fiber.StatusConflict).
+				Send([]byte("bookings can only be cancelled with POST /bookings/:id/cancel"))
+		}
+
+		booking, err := ops.WithActor(getActor(c)).TransitionBooking(id, change.Status, time.Now())
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		if !booking.Status.TakesTime() {
+			promoter.PromoteLater(booking.ServiceID)
+		}
+
+		return c.JSON(booking)
+	})
+
+	bookings.Get("/:id/transitions", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		transitions, err := ops.GetTransitions(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(transitions)
+	})
+
+	holds := app.Group("/holds")
+
+	holds.Get("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		hold, err := ops.GetHoldByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(hold)
+	})
+
+	holds.Post("/", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no hold provided"))
+		}
+
+		var newHold *types.Hold
+		if err := json.Unmarshal(c.Body(), &newHold); err != nil || newHold == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid hold provided"))
+		}
+
+		ttl := holdTTL
+		if newHold.TTL > 0 {
+			ttl = time.Duration(newHold.TTL) * time.Second
+			if ttl > maxHoldTTL {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte(fmt.Sprintf("holds cannot last more than %s", maxHoldTTL)))
+			}
+		}
+
+		service, err := checkSlot(services, timetables, loc, &types.Booking{
+			ServiceID: newHold.ServiceID,
+			Start:     newHold.Start,
+			End:       newHold.End,
+		})
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if isClass(service) {
+			return sendSlotError(c, errClassService)
+		}
+
+		createdHold, err := ops.CreateHold(newHold, ttl,
+			time.Duration(service.BufferBefore)*time.Minute, time.Duration(service.BufferAfter)*time.Minute)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(createdHold)
+	})
+
+	holds.Post("/:id/confirm", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no booking provided"))
+		}
+
+		var booking *types.Booking
+		if err := json.Unmarshal(c.Body(), &booking); err != nil || booking == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid booking provided"))
+		}
+
+		hold, err := ops.GetHoldByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		service, err := services.GetService(hold.ServiceID)
+		if err != nil {
+			return sendSlotError(c, fmt.Errorf("cannot get service %d: %w", hold.ServiceID, err))
+		}
+		booking.Status = initialStatus(service)
+
+		confirmed, err := ops.WithActor(getActor(c)).ConfirmHold(id, booking)
+		if err != nil {
+			if errors.Is(err, database.ErrHoldExpired) {
+				return c.Status(fiber.StatusGone).
+					Send([]byte(err.Error()))
+			}
+
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(confirmed)
+	})
+
+	holds.Delete("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if err := ops.ReleaseHold(id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	series := app.Group("/series")
+
+	series.Get("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		res, err := ops.GetSeriesByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	series.Post("/", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no series provided"))
+		}
+
+		var newSeries *types.Series
+		if err := json.Unmarshal(c.Body(), &newSeries); err != nil || newSeries == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid series provided"))
+		}
+
+		occurrences, err := expandSeries(newSeries, loc)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		service, err := services.GetService(newSeries.ServiceID)
+		if err != nil {
+			return sendSlotError(c, fmt.Errorf("cannot get service %d: %w", newSeries.ServiceID, err))
+		}
+
+		if isClass(service) {
+			return sendSlotError(c, errClassService)
+		}
+
+		// Every occurrence is checked, so that all the ones outside the
+		// timetable are reported at once.
+		duration := newSeries.End.Sub(newSeries.Start)
+		outside := []string{}
+		for _, start := range occurrences {
+			inside, err := insideTimetable(services, timetables, loc, service.ID, start, start.Add(duration))
+			if err != nil && !errors.Is(err, remote.ErrNoTimetable) {
+				return sendSlotError(c, err)
+			}
+
+			if !inside {
+				outside = append(outside, start.Format(time.RFC3339))
+			}
+		}
+
+		if len(outside) > 0 {
+			return sendSlotError(c, fmt.Errorf("%w: %s", errOutsideTimetable, strings.Join(outside, ", ")))
+		}
+
+		createdSeries, err := ops.WithActor(getActor(c)).CreateSeries(newSeries, occurrences, initialStatus(service),
+			time.Duration(service.BufferBefore)*time.Minute, time.Duration(service.BufferAfter)*time.Minute)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(createdSeries)
+	})
+
+	series.Delete("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		existing, err := ops.GetSeriesByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		// Occurrences that already started or ended are kept.
+		now := time.Now()
+		toCancel := []*types.Booking{}
+		for _, occurrence := range existing.Occurrences {
+			if occurrence.Status != types.OccurrenceSkipped &&
+				occurrence.Booking.Status.CanBecome(types.BookingCancelled) &&
+				!occurrence.Booking.Start.Before(now) {
+				toCancel = append(toCancel, occurrence.Booking)
+			}
+		}
+
+		outcomes, refused, err := cancelPolicies(services, toCancel, now)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if refused != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(refused)
+		}
+
+		cancellations := make([]database.Cancellation, len(toCancel))
+		for i, booking := range toCancel {
+			cancellations[i] = database.Cancellation{BookingID: booking.ID, Fee: outcomes[i].Fee}
+		}
+
+		cancelled, err := ops.WithActor(getActor(c)).CancelSeries(id, cancellations)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		for i := range cancelled {
+			outcomes[i].Booking = &cancelled[i]
+		}
+
+		if len(cancelled) > 0 {
+			promoter.PromoteLater(existing.ServiceID)
+		}
+
+		return c.JSON(outcomes)
+	})
+
+	series.Put("/:id/occurrences/:booking", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		seriesID, bookingID, err := getOccurrenceIDs(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no booking provided"))
+		}
+
+		var moved *types.Booking
+		if err := json.Unmarshal(c.Body(), &moved); err != nil || moved == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid booking provided"))
+		}
+
+		occurrence, err := ops.GetOccurrence(seriesID, bookingID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		service, err := checkSlot(services, timetables, loc, &types.Booking{
+			ServiceID: occurrence.ServiceID,
+			Start:     moved.Start,
+			End:       moved.End,
+		})
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		outcome, allowed, err := reschedulePolicy(services, occurrence, time.Now())
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if !allowed {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(outcome)
+		}
+
+		if outcome.Booking, err = ops.MoveOccurrence(seriesID, bookingID, moved.Start, moved.End,
+			occurrence.Reschedules, outcome.Fee,
+			time.Duration(service.BufferBefore)*time.Minute, time.Duration(service.BufferAfter)*time.Minute); err != nil {
+			return sendBookingError(c, err)
+		}
+
+		promoter.PromoteLater(occurrence.ServiceID)
+		return c.JSON(outcome)
+	})
+
+	series.Delete("/:id/occurrences/:booking", func(c *fiber.Ctx) error {
+		seriesID, bookingID, err := getOccurrenceIDs(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		occurrence, err := ops.GetOccurrence(seriesID, bookingID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		outcome, allowed, err := cancelPolicy(services, occurrence, time.Now())
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if !allowed {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(outcome)
+		}
+
+		if outcome.Booking, err = ops.WithActor(getActor(c)).SkipOccurrence(seriesID, bookingID, outcome.Fee); err != nil {
+			return sendBookingError(c, err)
+		}
+
+		promoter.PromoteLater(occurrence.ServiceID)
+		return c.JSON(outcome)
+	})
+
+	chains := app.Group("/chains")
+
+	chains.Get("/availability", func(c *fiber.Ctx) error {
+		ids := []uint{}
+		for _, value := range strings.Split(c.Query("services"), ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 0)
+			if err != nil || id == 0 {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid services provided"))
+			}
+
+			ids = append(ids, uint(id))
+		}
+
+		start, end, granularity, err := getAvailabilityQuery(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		chained, err := getChainServices(services, ids)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		segments := chainSegments(services, timetables, chained)
+		offsets := availability.ChainOffsets(segments)
+		for i := range segments {
+			// Segments start later than the chain, and bookings that end or
+			// start a day away can still take time with their buffers.
+			segments[i].Busy, err = ops.GetBusy(chained[i].ID,
+				start.Add(offsets[i]).AddDate(0, 0, -1), end.Add(offsets[i]).AddDate(0, 0, 1))
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).
+					Send([]byte(err.Error()))
+			}
+		}
+
+		slots, err := availability.ChainSlots(availability.ChainRequest{
+			Segments:    segments,
+			From:        start,
+			To:          end,
+			Granularity: granularity,
+		}, loc)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		return c.JSON(slots)
+	})
+
+	chains.Get("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		chain, err := ops.GetChainByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(chain)
+	})
+
+	chains.Post("/", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no chain provided"))
+		}
+
+		var newChain *types.Chain
+		if err := json.Unmarshal(c.Body(), &newChain); err != nil || newChain == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid chain provided"))
+		}
+
+		if newChain.Start.IsZero() {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no start provided"))
+		}
+
+		chained, err := getChainServices(services, newChain.Services)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		// The whole chain is checked, so that all the segments outside the
+		// timetables are reported at once.
+		segments := chainSegments(services, timetables, chained)
+		offsets := availability.ChainOffsets(segments)
+		toBook := make([]database.ChainSegment, len(segments))
+		outside := []string{}
+		for i, segment := range segments {
+			toBook[i] = database.ChainSegment{
+				ServiceID:    chained[i].ID,
+				Start:        newChain.Start.Add(offsets[i]),
+				End:          newChain.Start.Add(offsets[i] + segment.Duration),
+				BufferBefore: segment.BufferBefore,
+				BufferAfter:  segment.BufferAfter,
+				Status:       initialStatus(chained[i]),
+			}
+
+			inside, err := insideTimetable(services, timetables, loc, chained[i].ID, toBook[i].Start, toBook[i].End)
+			if err != nil && !errors.Is(err, remote.ErrNoTimetable) {
+				return sendSlotError(c, err)
+			}
+
+			if !inside {
+				outside = append(outside, fmt.Sprintf("service %d at %s", chained[i].ID, toBook[i].Start.Format(time.RFC3339)))
+			}
+		}
+
+		if len(outside) > 0 {
+			return sendSlotError(c, fmt.Errorf("%w: %s", errOutsideTimetable, strings.Join(outside, ", ")))
+		}
+
+		createdChain, err := ops.WithActor(getActor(c)).CreateChain(&types.Chain{
+			Customer: newChain.Customer,
+			Notes:    newChain.Notes,
+		}, toBook)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(createdChain)
+	})
+
+	chains.Delete("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		chain, err := ops.GetChainByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		// Segments that already started or ended are kept.
+		now := time.Now()
+		toCancel := []*types.Booking{}
+		for i := range chain.Bookings {
+			booking := &chain.Bookings[i]
+			if booking.Status.CanBecome(types.BookingCancelled) && !booking.Start.Before(now) {
+				toCancel = append(toCancel, booking)
+			}
+		}
+
+		outcomes, refused, err := cancelPolicies(services, toCancel, now)
+		if err != nil {
+			return sendSlotError(c, err)
+		}
+
+		if refused != nil {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(refused)
+		}
+
+		cancellations := make([]database.Cancellation, len(toCancel))
+		for i, booking := range toCancel {
+			cancellations[i] = database.Cancellation{BookingID: booking.ID, Fee: outcomes[i].Fee}
+		}
+
+		cancelled, err := ops.WithActor(getActor(c)).CancelChain(id, cancellations)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		for i := range cancelled {
+			outcomes[i].Booking = &cancelled[i]
+			promoter.PromoteLater(cancelled[i].ServiceID)
+		}
+
+		return c.JSON(outcomes)
+	})
+
+	classes := app.Group("/classes")
+
+	classes.Get("/", func(c *fiber.Ctx) error {
+		var serviceID uint
+		if c.Query("service") != "" {
+			id, err := strconv.ParseUint(c.Query("service"), 10, 0)
+			if err != nil || id == 0 {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid service provided"))
+			}
+
+			serviceID = uint(id)
+		}
+
+		start := time.Now()
+		if c.Query("from") != "" {
+			from, err := time.Parse(time.RFC3339, c.Query("from"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid from provided"))
+			}
+
+			start = from
+		}
+
+		end := start.AddDate(0, 0, defaultListDays)
+		if c.Query("to") != "" {
+			to, err := time.Parse(time.RFC3339, c.Query("to"))
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid to provided"))
+			}
+
+			end = to
+		}
+
+		res, err := ops.GetClassSessions(serviceID, start, end)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	classes.Get("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		session, err := ops.GetClassSessionByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(session)
+	})
+
+	classes.Post("/", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no session provided"))
+		}
+
+		var newSession *types.ClassSession
+		if err := json.Unmarshal(c.Body(), &newSession); err != nil || newSession == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid session provided"))
+		}
+
+		if newSession.ServiceID == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no service provided"))
+		}
+
+		service, err := services.GetService(newSession.ServiceID)
+		if err != nil {
+			return sendSlotError(c, fmt.Errorf("cannot get service %d: %w", newSession.ServiceID, err))
+		}
+
+		if !isClass(service) {
+			return c.Status(fiber.StatusUnprocessableEntity).
+				Send([]byte("the service is not a class"))
+		}
+
+		// Sessions last as much as the service, unless told otherwise.
+		end := newSession.End
+		if end.IsZero() && service.Duration > 0 {
+			end = newSession.Start.Add(time.Duration(service.Duration) * time.Minute)
+		}
+
+		if _, err := checkSlot(services, timetables, loc, &types.Booking{
+			ServiceID: service.ID,
+			Start:     newSession.Start,
+			End:       end,
+		}); err != nil {
+			return sendSlotError(c, err)
+		}
+
+		createdSession, err := ops.CreateClassSession(&types.ClassSession{
+			ServiceID: service.ID,
+			Start:     newSession.Start,
+			End:       end,
+		}, service.Capacity)
+		if err != nil {
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(createdSession)
+	})
+
+	classes.Delete("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if err := ops.DeleteClassSession(id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	classes.Get("/:id/attendees", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		res, err := ops.GetAttendees(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	classes.Post("/:id/attendees", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no attendee provided"))
+		}
+
+		var newAttendee *types.Attendee
+		if err := json.Unmarshal(c.Body(), &newAttendee); err != nil || newAttendee == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid attendee provided"))
+		}
+
+		session, err := ops.GetClassSessionByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		if !session.Start.After(time.Now()) {
+			return c.Status(fiber.StatusUnprocessableEntity).
+				Send([]byte("the session already started"))
+		}
+
+		attendee, err := ops.AddAttendee(id, newAttendee)
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return c.SendStatus(fiber.StatusNotFound)
+			case errors.Is(err, database.ErrSessionFull), errors.Is(err, database.ErrAlreadyAttending):
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(attendee)
+	})
+
+	classes.Delete("/:id/attendees/:attendee", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		attendeeID, err := strconv.ParseUint(c.Params("attendee"), 10, 0)
+		if err != nil || attendeeID == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid attendee provided"))
+		}
+
+		if err := ops.RemoveAttendee(id, uint(attendeeID)); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	wl := app.Group("/waitlist")
+
+	wl.Get("/", func(c *fiber.Ctx) error {
+		var serviceID uint
+		if c.Query("service") != "" {
+			id, err := strconv.ParseUint(c.Query("service"), 10, 0)
+			if err != nil || id == 0 {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid service provided"))
+			}
+
+			serviceID = uint(id)
+		}
+
+		res, err := ops.GetWaitlist(serviceID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	wl.Get("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		entry, err := ops.GetWaitlistEntryByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(entry)
+	})
+
+	wl.Post("/", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no entry provided"))
+		}
+
+		var newEntry *types.WaitlistEntry
+		if err := json.Unmarshal(c.Body(), &newEntry); err != nil || newEntry == nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid entry provided"))
+		}
+
+		if !newEntry.To.After(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("the window is in the past"))
+		}
+
+		if newEntry.To.After(newEntry.From.AddDate(0, 0, maxAvailabilityDays)) {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(fmt.Sprintf("cannot wait for more than %d days", maxAvailabilityDays)))
+		}
+
+		if _, err := services.GetService(newEntry.ServiceID); err != nil {
+			if errors.Is(err, remote.ErrNotFound) {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid service provided"))
+			}
+
+			return c.Status(fiber.StatusBadGateway).
+				Send([]byte(err.Error()))
+		}
+
+		createdEntry, err := ops.CreateWaitlistEntry(&types.WaitlistEntry{
+			ServiceID: newEntry.ServiceID,
+			From:      newEntry.From,
+			To:        newEntry.To,
+			Customer:  newEntry.Customer,
+			Notes:     newEntry.Notes,
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		promoter.PromoteLater(createdEntry.ServiceID)
+		return c.Status(fiber.StatusCreated).JSON(createdEntry)
+	})
+
+	wl.Post("/:id/accept", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		entry, err := ops.GetWaitlistEntryByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		service, err := services.GetService(entry.ServiceID)
+		if err != nil {
+			return sendSlotError(c, fmt.Errorf("cannot get service %d: %w", entry.ServiceID, err))
+		}
+
+		booking, err := ops.WithActor(getActor(c)).AcceptOffer(id, initialStatus(service))
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrNoOffer):
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			case errors.Is(err, database.ErrHoldExpired):
+				return c.Status(fiber.StatusGone).
+					Send([]byte(err.Error()))
+			}
+
+			return sendBookingError(c, err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(booking)
+	})
+
+	wl.Post("/:id/decline", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		entry, err := ops.DeclineOffer(id)
+		if err != nil {
+			if errors.Is(err, database.ErrNoOffer) {
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			}
+
+			return sendBookingError(c, err)
+		}
+
+		promoter.PromoteLater(entry.ServiceID)
+		return c.JSON(entry)
+	})
+
+	wl.Delete("/:id", func(c *fiber.Ctx) error {
+		id, err := getBookingID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		entry, err := ops.GetWaitlistEntryByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		if err := ops.DeleteWaitlistEntry(id); err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		if entry.Status == types.WaitlistOffered {
+			promoter.PromoteLater(entry.ServiceID)
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	// -----------------------------------------
+	// Sweep expired holds and offers
+	// -----------------------------------------
+
+	stopSweeper := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(holdSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopSweeper:
+				return
+			case now := <-ticker.C:
+				if expired, err := ops.ExpireHolds(now); err != nil {
+					log.Err(err).Msg("could not expire holds")
+				} else if expired > 0 {
+					log.Debug().Int64("expired", expired).Msg("expired holds")
+				}
+
+				if err := promoter.Expire(now); err != nil {
+					log.Err(err).Msg("could not expire offers")
+				}
+
+				// Slots can be freed in many ways, e.g. by holds expiring, so
+				// all the waitlists are checked.
+				if err := promoter.PromoteAll(); err != nil {
+					log.Err(err).Msg("could not promote waitlists")
+				}
+			}
+		}
+	}()
+
+	go func() {
+		if err := app.Listen(":8080"); err != nil {
+			log.Err(err).Msg("error while listening")
+		}
+	}()
+
+	// -----------------------------------------
+	// Graceful shutdown
+	// -----------------------------------------
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+
+	log.Info().Msg("shutting down...")
+	close(stopSweeper)
+	if err := app.Shutdown(); err != nil {
+		log.Err(err).Msg("error while waiting for server to shutdown")
+	}
+	log.Info().Msg("goodbye!")
+}
+
+func getBookingID(c *fiber.Ctx) (uint, error) {
+	bookingID, err := url.PathUnescape(c.Params("id"))
+	if err != nil || bookingID == "" {
+		return 0, fmt.Errorf("invalid id provided")
+	}
+
+	id, err := strconv.ParseUint(bookingID, 10, 0)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid id provided")
+	}
+
+	return uint(id), nil
+}
+
+// getOccurrenceIDs returns the IDs of the series and of the booking of an
+// occurrence.
+func getOccurrenceIDs(c *fiber.Ctx) (uint, uint, error) {
+	seriesID, err := getBookingID(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bookingID, err := strconv.ParseUint(c.Params("booking"), 10, 0)
+	if err != nil || bookingID == 0 {
+		return 0, 0, fmt.Errorf("invalid booking provided")
+	}
+
+	return seriesID, uint(bookingID), nil
+}
+
+// getPolicy returns the service and the policy in effect for it, which is
+// nil if neither the service nor its parents have one.
+func getPolicy(services *remote.Services, serviceID uint) (*servicestypes.Service, *servicestypes.EffectivePolicy, error) {
+	service, err := services.GetService(serviceID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get service %d: %w", serviceID, err)
+	}
+
+	effective, err := services.GetPolicy(serviceID)
+	if err != nil {
+		if errors.Is(err, remote.ErrNotFound) {
+			return service, nil, nil
+		}
+
+		return nil, nil, fmt.Errorf("cannot get policy of service %d: %w", serviceID, err)
+	}
+
+	return service, effective, nil
+}
+
+// cancelPolicy tells whether the booking can be cancelled at now, and with
+// which fee, following the cancellation policy in effect for its service.
+func cancelPolicy(services *remote.Services, booking *types.Booking, now time.Time) (*types.PolicyOutcome, bool, error) {
+	service, effective, err := getPolicy(services, booking.ServiceID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	outcome, allowed := decideCancel(service, effective, booking, now)
+	return outcome, allowed, nil
+}
+
+// cancelPolicies applies cancelPolicy to each of the bookings, getting the
+// policy of each service once. If one of them cannot be cancelled, its
+// outcome is returned as refused, with the booking.
+func cancelPolicies(services *remote.Services, bookings []*types.Booking, now time.Time) ([]*types.PolicyOutcome, *types.PolicyOutcome, error) {
+	type servicePolicy struct {
+		service   *servicestypes.Service
+		effective *servicestypes.EffectivePolicy
+	}
+	policies := map[uint]servicePolicy{}
+
+	outcomes := make([]*types.PolicyOutcome, len(bookings))
+	for i, booking := range bookings {
+		got, found := policies[booking.ServiceID]
+		if !found {
+			service, effective, err := getPolicy(services, booking.ServiceID)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			got = servicePolicy{service: service, effective: effective}
+			policies[booking.ServiceID] = got
+		}
+
+		outcome, allowed := decideCancel(got.service, got.effective, booking, now)
+		if !allowed {
+			outcome.Booking = booking
+			return nil, outcome, nil
+		}
+
+		outcomes[i] = outcome
+	}
+
+	return outcomes, nil, nil
+}
+
+// decideCancel applies the cancellation policy in effect for the service
+// to the booking.
+func decideCancel(service *servicestypes.Service, effective *servicestypes.EffectivePolicy, booking *types.Booking, now time.Time) (*types.PolicyOutcome, bool) {
+	outcome := &types.PolicyOutcome{}
+	var rules *servicestypes.Policy
+	if effective != nil {
+		outcome.PolicyServiceID, rules = effective.ServiceID, effective.Policy
+	}
+
+	decision := policy.Cancel(rules, servicePrice(service), booking.Start, now)
+	outcome.Fee, outcome.Rule = decision.Fee, decision.Rule
+
+	return outcome, decision.Allowed
+}
+
+// reschedulePolicy tells whether the booking can be moved at now, and with
+// which fee, following the reschedule policy in effect for its service.
+func reschedulePolicy(services *remote.Services, booking *types.Booking, now time.Time) (*types.PolicyOutcome, bool, error) {
+	service, effective, err := getPolicy(services, booking.ServiceID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	outcome := &types.PolicyOutcome{}
+	var rules *servicestypes.Policy
+	if effective != nil {
+		outcome.PolicyServiceID, rules = effective.ServiceID, effective.Policy
+	}
+
+	decision := policy.Reschedule(rules, servicePrice(service), booking.Start, now, booking.Reschedules)
+	outcome.Fee, outcome.Rule = decision.Fee, decision.Rule
+
+	return outcome, decision.Allowed, nil
+}
+
+// servicePrice returns the price of the service, or 0 if it has none.
+func servicePrice(service *servicestypes.Service) float64 {
+	if service.Price == nil {
+		return 0
+	}
+
+	return *service.Price
+}
+
+// getAvailabilityQuery returns the range and the granularity of an
+// availability request. Slots in the past cannot be booked, so the range
+// starts from now at the earliest.
+func getAvailabilityQuery(c *fiber.Ctx) (time.Time, time.Time, time.Duration, error) {
+	var err error
+	now := time.Now()
+	start := now
+	if c.Query("from") != "" {
+		if start, err = time.Parse(time.RFC3339, c.Query("from")); err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid from provided")
+		}
+	}
+
+	end := start.AddDate(0, 0, defaultAvailabilityDays)
+	if c.Query("to") != "" {
+		if end, err = time.Parse(time.RFC3339, c.Query("to")); err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid to provided")
+		}
+	}
+
+	if end.After(start.AddDate(0, 0, maxAvailabilityDays)) {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("cannot get more than %d days", maxAvailabilityDays)
+	}
+
+	if start.Before(now) {
+		start = now
+	}
+
+	granularity := availability.DefaultGranularity
+	if c.Query("granularity") != "" {
+		minutes, err := strconv.Atoi(c.Query("granularity"))
+		if err != nil || minutes < minGranularityMinutes {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid granularity provided")
+		}
+
+		granularity = time.Duration(minutes) * time.Minute
+	}
+
+	return start, end, granularity, nil
+}
+
+// getChainServices returns the services of a chain, in order. They must be
+// bookable by slot and have a duration.
+func getChainServices(services *remote.Services, ids []uint) ([]*servicestypes.Service, error) {
+	switch {
+	case len(ids) == 0:
+		return nil, fmt.Errorf("%w: no services provided", errInvalidBooking)
+	case len(ids) > maxChainServices:
+		return nil, fmt.Errorf("%w: cannot chain more than %d services", errInvalidBooking, maxChainServices)
+	}
+
+	chained := make([]*servicestypes.Service, len(ids))
+	for i, id := range ids {
+		if id == 0 {
+			return nil, fmt.Errorf("%w: invalid service provided", errInvalidBooking)
+		}
+
+		service, err := services.GetService(id)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get service %d: %w", id, err)
+		}
+
+		if isClass(service) {
+			return nil, fmt.Errorf("%w: service %d", errClassService, id)
+		}
+
+		if service.Duration == 0 {
+			return nil, fmt.Errorf("%w: service %d has no duration", errInvalidBooking, id)
+		}
+
+		chained[i] = service
+	}
+
+	return chained, nil
+}
+
+// chainSegments returns the segments of a chain of the services, without
+// busy times.
+func chainSegments(services *remote.Services, timetables *remote.Timetables, chained []*servicestypes.Service) []availability.Segment {
+	segments := make([]availability.Segment, len(chained))
+	for i, service := range chained {
+		segments[i] = availability.Segment{
+			Duration:     time.Duration(service.Duration) * time.Minute,
+			BufferBefore: time.Duration(service.BufferBefore) * time.Minute,
+			BufferAfter:  time.Duration(service.BufferAfter) * time.Minute,
+			Resolve:      remote.ServiceTimetables(services, timetables, service.ID),
+		}
+	}
+
+	return segments
+}
+
+// isClass tells whether the service is booked by seats of its sessions
+// rather than by slots.
+func isClass(service *servicestypes.Service) bool {
+	return service.Capacity > 1
+}
+
+// checkSlot verifies that the timetable in effect for the service of the
+// booking is open for the whole booking and returns the service. The
+// timetable is the one in effect on the day the booking starts, in loc.
+func checkSlot(services *remote.Services, timetables *remote.Timetables, loc *time.Location, booking *types.Booking) (*servicestypes.Service, error) {
+	if booking == nil {
+		return nil, fmt.Errorf("%w: no booking provided", errInvalidBooking)
+	}
+
+	if booking.ServiceID == 0 {
+		return nil, fmt.Errorf("%w: no service provided", errInvalidBooking)
+	}
+
+	if !booking.End.After(booking.Start) {
+		return nil, fmt.Errorf("%w: invalid end provided", errInvalidBooking)
+	}
+
+	service, err := services.GetService(booking.ServiceID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get service %d: %w", booking.ServiceID, err)
+	}
+
+	inside, err := insideTimetable(services, timetables, loc, booking.ServiceID, booking.Start, booking.End)
+	if err != nil {
+		return nil, err
+	}
+
+	if !inside {
+		return nil, errOutsideTimetable
+	}
+
+	return service, nil
+}
+
+// insideTimetable tells whether the timetable in effect for the service on
+// the day of start, in loc, is open from start to end.
+func insideTimetable(services *remote.Services, timetables *remote.Timetables, loc *time.Location, serviceID uint, start, end time.Time) (bool, error) {
+	tt, err := remote.EffectiveTimetable(services, timetables, serviceID, start.In(loc))
+	if err != nil {
+		return false, err
+	}
+
+	return availability.InsideTimetable(tt, start, end)
+}
+
+// expandSeries returns the start times of the occurrences of the series,
+// in loc. The rule must end within maxSeriesYears and have at most
+// maxSeriesOccurrences occurrences.
+func expandSeries(series *types.Series, loc *time.Location) ([]time.Time, error) {
+	if series == nil {
+		return nil, fmt.Errorf("%w: no series provided", errInvalidBooking)
+	}
+
+	if series.ServiceID == 0 {
+		return nil, fmt.Errorf("%w: no service provided", errInvalidBooking)
+	}
+
+	if series.Start.IsZero() || !series.End.After(series.Start) {
+		return nil, fmt.Errorf("%w: invalid start or end provided", errInvalidBooking)
+	}
+
+	rule, err := ical.ParseRecurrence(series.RRule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid rrule provided: %s", errInvalidBooking, err)
+	}
+
+	if rule.Count == 0 && rule.Until == nil {
+		return nil, fmt.Errorf("%w: the rrule must have COUNT or UNTIL", errInvalidBooking)
+	}
+
+	start := series.Start.In(loc)
+	horizon := start.AddDate(maxSeriesYears, 0, 0)
+	if rule.Until != nil && rule.Until.After(horizon) {
+		return nil, fmt.Errorf("%w: the rrule cannot go past %d years", errInvalidBooking, maxSeriesYears)
+	}
+
+	occurrences := rule.Occurrences(start, horizon)
+	switch {
+	case len(occurrences) == 0:
+		return nil, fmt.Errorf("%w: the rrule has no occurrences", errInvalidBooking)
+	case rule.Count > 0 && len(occurrences) < rule.Count:
+		return nil, fmt.Errorf("%w: the rrule cannot go past %d years", errInvalidBooking, maxSeriesYears)
+	case len(occurrences) > maxSeriesOccurrences:
+		return nil, fmt.Errorf("%w: the rrule cannot have more than %d occurrences", errInvalidBooking, maxSeriesOccurrences)
+	}
+
+	return occurrences, nil
+}
+
+// sendSlotError replies with the status code that fits an error returned
+// by checkSlot or by the other APIs: services that do not exist are the
+// fault of the request, not of the other APIs.
+func sendSlotError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, remote.ErrNoTimetable), errors.Is(err, errOutsideTimetable),
+		errors.Is(err, errClassService):
+		return c.Status(fiber.StatusUnprocessableEntity).
+			Send([]byte(err.Error()))
+	case errors.Is(err, errInvalidBooking), errors.Is(err, remote.ErrNotFound):
+		return c.Status(fiber.StatusBadRequest).
+			Send([]byte(err.Error()))
+	default:
+		return c.Status(fiber.StatusBadGateway).
+			Send([]byte(err.Error()))
+	}
+}
+
+// sendBookingError replies with the status code that fits an error returned
+// when writing a booking: conflicts are replied with the booking that
+// takes the time.
+func sendBookingError(c *fiber.Ctx, err error) error {
+	var conflict *database.ConflictError
+	if errors.As(err, &conflict) {
+		return c.Status(fiber.StatusConflict).JSON(&types.Conflict{
+			Message:   database.ErrConflict.Error(),
+			BookingID: conflict.Booking.ID,
+			Held:      conflict.Held,
+			Start:     conflict.Booking.Start,
+			End:       conflict.Booking.End,
+		})
+	}
+
+	switch {
+	case errors.Is(err, database.ErrConflict), errors.Is(err, database.ErrChanged),
+		errors.Is(err, database.ErrInvalidTransition):
+		return c.Status(fiber.StatusConflict).
+			Send([]byte(err.Error()))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.SendStatus(fiber.StatusNotFound)
+	default:
+		return c.Status(fiber.StatusInternalServerError).
+			Send([]byte(err.Error()))
+	}
+}
+
+// initialStatus returns the status new bookings of the service start with.
+func initialStatus(service *servicestypes.Service) types.BookingStatus {
+	if service.RequiresApproval {
+		return types.BookingRequested
+	}
+
+	return types.BookingConfirmed
+}
+
+// getActor returns who is making the request, if provided.
+func getActor(c *fiber.Ctx) string {
+	return text.Truncate(strings.TrimSpace(c.Get(actorHeader)), maxActorLength)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/appointments/internal/remote"
+	"github.com/asimpleidea/appoint/api/appointments/pkg/types"
+	servicestypes "github.com/asimpleidea/appoint/api/services/pkg/types"
+)
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func uintPtr(value uint) *uint {
+	return &value
+}
+
+// fakeServices serves the services and their effective policies as the
+// services API does, counting the requests for each path.
+type fakeServices struct {
+	services map[string]servicestypes.Service
+	policies map[string]servicestypes.EffectivePolicy
+
+	lock     sync.Mutex
+	requests map[string]int
+}
+
+func (f *fakeServices) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	f.requests[r.URL.Path]++
+	f.lock.Unlock()
+
+	id := strings.TrimPrefix(r.URL.Path, "/services/")
+	if strings.HasSuffix(id, "/policy") {
+		policy, found := f.policies[strings.TrimSuffix(id, "/policy")]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(policy)
+		return
+	}
+
+	service, found := f.services[id]
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(service)
+}
+
+// newFakeServices returns a services API where service 2 inherits the
+// policy of its parent, service 1, service 3 overrides it with its own and
+// service 4 has none.
+func newFakeServices(t *testing.T) (*fakeServices, *remote.Services) {
+	parentPolicy := &servicestypes.Policy{
+		Cancellation:   []servicestypes.FeeRule{{WithinHours: 24, FeePercent: 50}},
+		Reschedule:     []servicestypes.FeeRule{{WithinHours: 24, FeePercent: 25}},
+		MaxReschedules: uintPtr(1),
+	}
+	ownPolicy := &servicestypes.Policy{MaxReschedules: uintPtr(0)}
+
+	fake := &fakeServices{
+		services: map[string]servicestypes.Service{
+			"1": {ID: 1, Price: floatPtr(100), Policy: parentPolicy},
+			"2": {ID: 2, ParentID: uintPtr(1), Price: floatPtr(60)},
+			"3": {ID: 3, ParentID: uintPtr(1), Price: floatPtr(60), Policy: ownPolicy},
+			"4": {ID: 4, Price: floatPtr(60)},
+		},
+		policies: map[string]servicestypes.EffectivePolicy{
+			"1": {ServiceID: 1, Policy: parentPolicy},
+			"2": {ServiceID: 1, Policy: parentPolicy},
+			"3": {ServiceID: 3, Policy: ownPolicy},
+		},
+		requests: map[string]int{},
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, &remote.Services{URL: server.URL, Client: server.Client()}
+}
+
+func TestCancelPolicy(t *testing.T) {
+	_, services := newFakeServices(t)
+	now := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		serviceID uint
+		start     time.Time
+		want      types.PolicyOutcome
+	}{
+		{
+			name:      "inherited from the parent, on the price of the child",
+			serviceID: 2,
+			start:     now.Add(time.Hour),
+			want: types.PolicyOutcome{
+				Fee:             30,
+				Rule:            "cancellation within 24h of the start: 50% fee",
+				PolicyServiceID: 1,
+			},
+		},
+		{
+			name:      "inherited, outside the window",
+			serviceID: 2,
+			start:     now.Add(48 * time.Hour),
+			want:      types.PolicyOutcome{PolicyServiceID: 1},
+		},
+		{
+			name:      "own policy overrides the parent",
+			serviceID: 3,
+			start:     now.Add(time.Hour),
+			want:      types.PolicyOutcome{PolicyServiceID: 3},
+		},
+		{
+			name:      "no policy",
+			serviceID: 4,
+			start:     now.Add(time.Hour),
+			want:      types.PolicyOutcome{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, allowed, err := cancelPolicy(services, &types.Booking{ServiceID: tc.serviceID, Start: tc.start}, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !allowed {
+				t.Errorf("cancellation refused: %+v", got)
+			}
+
+			if *got != tc.want {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReschedulePolicy(t *testing.T) {
+	_, services := newFakeServices(t)
+	now := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		serviceID   uint
+		reschedules uint
+		wantAllowed bool
+		want        types.PolicyOutcome
+	}{
+		{
+			name:        "inherited fee",
+			serviceID:   2,
+			wantAllowed: true,
+			want: types.PolicyOutcome{
+				Fee:             15,
+				Rule:            "reschedule within 24h of the start: 25% fee",
+				PolicyServiceID: 1,
+			},
+		},
+		{
+			name:        "inherited limit",
+			serviceID:   2,
+			reschedules: 1,
+			want:        types.PolicyOutcome{Rule: "at most 1 reschedules", PolicyServiceID: 1},
+		},
+		{
+			name:      "own limit",
+			serviceID: 3,
+			want:      types.PolicyOutcome{Rule: "at most 0 reschedules", PolicyServiceID: 3},
+		},
+		{
+			name:        "no policy",
+			serviceID:   4,
+			reschedules: 10,
+			wantAllowed: true,
+			want:        types.PolicyOutcome{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, allowed, err := reschedulePolicy(services, &types.Booking{
+				ServiceID:   tc.serviceID,
+				Start:       now.Add(time.Hour),
+				Reschedules: tc.reschedules,
+			}, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if allowed != tc.wantAllowed {
+				t.Errorf("allowed is %t, want %t", allowed, tc.wantAllowed)
+			}
+
+			if *got != tc.want {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCancelPolicies(t *testing.T) {
+	fake, services := newFakeServices(t)
+	now := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	bookings := []*types.Booking{
+		{ID: 1, ServiceID: 2, Start: now.Add(time.Hour)},
+		{ID: 2, ServiceID: 4, Start: now.Add(2 * time.Hour)},
+		{ID: 3, ServiceID: 2, Start: now.Add(72 * time.Hour)},
+	}
+
+	outcomes, refused, err := cancelPolicies(services, bookings, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if refused != nil {
+		t.Fatalf("cancellation refused: %+v", refused)
+	}
+
+	wantFees := []float64{30, 0, 0}
+	if len(outcomes) != len(wantFees) {
+		t.Fatalf("got %d outcomes, want %d", len(outcomes), len(wantFees))
+	}
+
+	for i, outcome := range outcomes {
+		if outcome.Fee != wantFees[i] {
+			t.Errorf("booking %d: got fee %g, want %g", bookings[i].ID, outcome.Fee, wantFees[i])
+		}
+	}
+
+	for path, count := range fake.requests {
+		if count != 1 {
+			t.Errorf("%s was requested %d times, want once", path, count)
+		}
+	}
+}
+
+func TestCancelPoliciesMissingService(t *testing.T) {
+	_, services := newFakeServices(t)
+	now := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	_, _, err := cancelPolicies(services, []*types.Booking{
+		{ID: 1, ServiceID: 2, Start: now.Add(time.Hour)},
+		{ID: 2, ServiceID: 99, Start: now.Add(time.Hour)},
+	}, now)
+	if err == nil {
+		t.Fatal("expected an error for a missing service")
+	}
+}
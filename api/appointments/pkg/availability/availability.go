@@ -0,0 +1,187 @@
+// Package availability tells when services can be booked, from their
+// timetables, durations and buffers and the existing bookings.
+//
+// It does not access any database or API: callers provide everything that
+// is needed.
+package availability
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/schedule"
+	timetablestypes "github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const (
+	// DefaultGranularity is the distance between the start times of
+	// consecutive slots, if not provided.
+	DefaultGranularity time.Duration = 15 * time.Minute
+)
+
+// Slot is a time when a service can be booked.
+type Slot struct {
+	Start time.Time `json:"start" yaml:"start"`
+	End   time.Time `json:"end" yaml:"end"`
+}
+
+// Request contains what is needed to compute the slots of a service.
+type Request struct {
+	// Timetable tells when the service is provided. It must have been
+	// loaded in full.
+	Timetable *timetablestypes.Timetable
+	// From and To bound the start times of the slots, From included and To
+	// excluded.
+	From time.Time
+	To   time.Time
+	// Duration is how long the service takes.
+	Duration time.Duration
+	// BufferBefore and BufferAfter are the time to keep free before and
+	// after the service. They can be outside the timetable, but not during
+	// busy times.
+	BufferBefore time.Duration
+	BufferAfter  time.Duration
+	// Granularity is the distance between the start times of consecutive
+	// slots, which are aligned to the start of each open interval.
+	Granularity time.Duration
+	// Busy are the times that are already taken, including the buffers of
+	// what takes them: see Occupied.
+	Busy []interval.Interval
+}
+
+// Occupied returns the time taken by a booking from start to end, with
+// its buffers.
+func Occupied(start, end time.Time, bufferBefore, bufferAfter time.Duration) interval.Interval {
+	return interval.Interval{
+		Start: start.Add(-bufferBefore),
+		End:   end.Add(bufferAfter),
+	}
+}
+
+// Slots returns the slots that start between From and To, sorted by start.
+func Slots(req Request) ([]Slot, error) {
+	if req.Timetable == nil {
+		return nil, fmt.Errorf("no timetable provided")
+	}
+
+	if req.Duration <= 0 {
+		return nil, fmt.Errorf("invalid duration provided")
+	}
+
+	if req.BufferBefore < 0 || req.BufferAfter < 0 {
+		return nil, fmt.Errorf("invalid buffers provided")
+	}
+
+	granularity := req.Granularity
+	if granularity == 0 {
+		granularity = DefaultGranularity
+	}
+
+	if granularity < 0 {
+		return nil, fmt.Errorf("invalid granularity provided")
+	}
+
+	slots := []Slot{}
+	if !req.From.Before(req.To) {
+		return slots, nil
+	}
+
+	// A day more on each side, so that slots are aligned to the actual start
+	// of the intervals and the ones that go past To are found.
+	intervals, err := schedule.Expand(req.Timetable, req.From.AddDate(0, 0, -1), req.To.Add(req.Duration).AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	busy := interval.Normalize(req.Busy)
+	for _, open := range interval.Normalize(intervals) {
+		for start := open.Start; !start.Add(req.Duration).After(open.End); start = start.Add(granularity) {
+			if start.Before(req.From) {
+				continue
+			}
+
+			if !start.Before(req.To) {
+				break
+			}
+
+			end := start.Add(req.Duration)
+			if overlapsAny(busy, Occupied(start, end, req.BufferBefore, req.BufferAfter)) {
+				continue
+			}
+
+			slots = append(slots, Slot{Start: start, End: end})
+		}
+	}
+
+	return slots, nil
+}
+
+// Resolver returns the timetable in effect on the day of date, or nil if
+// there is none.
+type Resolver func(date time.Time) (*timetablestypes.Timetable, error)
+
+// SlotsByDay returns the slots that start between From and To, sorted by
+// start, when each day, in loc, can have a different timetable: the one
+// returned by resolve. The Timetable of req is ignored.
+func SlotsByDay(req Request, loc *time.Location, resolve Resolver) ([]Slot, error) {
+	start, end := req.From, req.To
+
+	slots := []Slot{}
+	first := start.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); day.Before(end); day = day.AddDate(0, 0, 1) {
+		req.From, req.To = day, day.AddDate(0, 0, 1)
+		if req.From.Before(start) {
+			req.From = start
+		}
+
+		if req.To.After(end) {
+			req.To = end
+		}
+
+		tt, err := resolve(day)
+		if err != nil {
+			return nil, err
+		}
+
+		if tt == nil {
+			continue
+		}
+		req.Timetable = tt
+
+		daySlots, err := Slots(req)
+		if err != nil {
+			return nil, err
+		}
+
+		slots = append(slots, daySlots...)
+	}
+
+	return slots, nil
+}
+
+// InsideTimetable tells whether the timetable is open for the whole time
+// from start to end. The timetable must have been loaded in full.
+func InsideTimetable(tt *timetablestypes.Timetable, start, end time.Time) (bool, error) {
+	if !end.After(start) {
+		return false, nil
+	}
+
+	intervals, err := schedule.Expand(tt, start, end)
+	if err != nil {
+		return false, err
+	}
+
+	merged := interval.Normalize(intervals)
+	return len(merged) == 1 && !merged[0].Start.After(start) && !merged[0].End.Before(end), nil
+}
+
+func overlapsAny(intervals []interval.Interval, other interval.Interval) bool {
+	for _, i := range intervals {
+		if i.Overlaps(other) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,335 @@
+package availability
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	timetablestypes "github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const slotFormat string = "2006-01-02 15:04 -07:00"
+
+var rome = mustLoadLocation("Europe/Rome")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return loc
+}
+
+// at returns the instant in Rome, parsed as "2006-01-02 15:04".
+func at(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", value, rome)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func openDay(dow timetablestypes.DOW, opening, closing string) timetablestypes.TimetableDay {
+	return timetablestypes.TimetableDay{DayOfWeek: dow, Opening: opening, Closing: closing, Kind: timetablestypes.KindOpen}
+}
+
+// testTimetable is open on Mondays from 09:00 to 12:00 and from 14:00 to
+// 16:00, on Tuesdays from 09:10 to 10:00 and on Sundays from 01:00 to 04:00,
+// so that daylight saving time changes happen while it is open. It is
+// closed on Easter Monday 2024.
+func testTimetable() *timetablestypes.Timetable {
+	return &timetablestypes.Timetable{
+		ValidFrom:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Timezone:      "Europe/Rome",
+		RotationWeeks: 1,
+		Monday: []timetablestypes.TimetableDay{
+			openDay(timetablestypes.Monday, "09:00", "12:00"),
+			openDay(timetablestypes.Monday, "14:00", "16:00"),
+		},
+		Tuesday: []timetablestypes.TimetableDay{
+			openDay(timetablestypes.Tuesday, "09:10", "10:00"),
+		},
+		Sunday: []timetablestypes.TimetableDay{
+			openDay(timetablestypes.Sunday, "01:00", "04:00"),
+		},
+		Exceptions: []timetablestypes.TimetableException{
+			{Date: "2024-04-01", Name: "Easter Monday", Closed: true},
+		},
+	}
+}
+
+func starts(slots []Slot) []string {
+	formatted := []string{}
+	for _, slot := range slots {
+		formatted = append(formatted, slot.Start.In(rome).Format(slotFormat))
+	}
+
+	return formatted
+}
+
+func TestSlots(t *testing.T) {
+	cases := []struct {
+		name string
+		req  Request
+		want []string
+	}{
+		{
+			name: "aligned to the start of the intervals",
+			req: Request{
+				From: at("2024-03-26 00:00"), To: at("2024-03-27 00:00"),
+				Duration: 20 * time.Minute, Granularity: 15 * time.Minute,
+			},
+			want: []string{"2024-03-26 09:10 +01:00", "2024-03-26 09:25 +01:00", "2024-03-26 09:40 +01:00"},
+		},
+		{
+			name: "aligned to the intervals and not to From",
+			req: Request{
+				From: at("2024-03-25 10:50"), To: at("2024-03-25 15:00"),
+				Duration: 30 * time.Minute, Granularity: 30 * time.Minute,
+			},
+			want: []string{
+				"2024-03-25 11:00 +01:00", "2024-03-25 11:30 +01:00",
+				"2024-03-25 14:00 +01:00", "2024-03-25 14:30 +01:00",
+			},
+		},
+		{
+			name: "default granularity",
+			req: Request{
+				From: at("2024-03-26 00:00"), To: at("2024-03-27 00:00"),
+				Duration: 45 * time.Minute,
+			},
+			want: []string{"2024-03-26 09:10 +01:00"},
+		},
+		{
+			name: "buffers can be outside the timetable",
+			req: Request{
+				From: at("2024-03-25 00:00"), To: at("2024-03-26 00:00"),
+				Duration: time.Hour, Granularity: time.Hour,
+				BufferBefore: 15 * time.Minute, BufferAfter: 15 * time.Minute,
+			},
+			want: []string{
+				"2024-03-25 09:00 +01:00", "2024-03-25 10:00 +01:00", "2024-03-25 11:00 +01:00",
+				"2024-03-25 14:00 +01:00", "2024-03-25 15:00 +01:00",
+			},
+		},
+		{
+			name: "busy times touching the slots",
+			req: Request{
+				From: at("2024-03-25 09:00"), To: at("2024-03-25 12:00"),
+				Duration: 30 * time.Minute, Granularity: 30 * time.Minute,
+				Busy: []interval.Interval{Occupied(at("2024-03-25 10:30"), at("2024-03-25 11:00"), 0, 0)},
+			},
+			want: []string{
+				"2024-03-25 09:00 +01:00", "2024-03-25 09:30 +01:00", "2024-03-25 10:00 +01:00",
+				"2024-03-25 11:00 +01:00", "2024-03-25 11:30 +01:00",
+			},
+		},
+		{
+			name: "buffers cannot be during busy times",
+			req: Request{
+				From: at("2024-03-25 09:00"), To: at("2024-03-25 12:00"),
+				Duration: 30 * time.Minute, Granularity: 30 * time.Minute,
+				BufferBefore: 15 * time.Minute, BufferAfter: 15 * time.Minute,
+				Busy: []interval.Interval{Occupied(at("2024-03-25 10:30"), at("2024-03-25 11:00"), 0, 0)},
+			},
+			want: []string{"2024-03-25 09:00 +01:00", "2024-03-25 09:30 +01:00", "2024-03-25 11:30 +01:00"},
+		},
+		{
+			name: "buffers of busy times",
+			req: Request{
+				From: at("2024-03-25 09:00"), To: at("2024-03-25 12:00"),
+				Duration: 30 * time.Minute, Granularity: 30 * time.Minute,
+				Busy: []interval.Interval{Occupied(at("2024-03-25 10:30"), at("2024-03-25 11:00"), 10*time.Minute, 10*time.Minute)},
+			},
+			want: []string{"2024-03-25 09:00 +01:00", "2024-03-25 09:30 +01:00", "2024-03-25 11:30 +01:00"},
+		},
+		{
+			name: "slots can end after To",
+			req: Request{
+				From: at("2024-03-25 09:00"), To: at("2024-03-25 11:15"),
+				Duration: time.Hour, Granularity: 30 * time.Minute,
+			},
+			want: []string{
+				"2024-03-25 09:00 +01:00", "2024-03-25 09:30 +01:00", "2024-03-25 10:00 +01:00",
+				"2024-03-25 10:30 +01:00", "2024-03-25 11:00 +01:00",
+			},
+		},
+		{
+			name: "slots cannot end after the interval",
+			req: Request{
+				From: at("2024-03-26 00:00"), To: at("2024-03-27 00:00"),
+				Duration: time.Hour,
+			},
+			want: []string{},
+		},
+		{
+			name: "spring forward",
+			req: Request{
+				From: at("2024-03-31 00:00"), To: at("2024-04-01 00:00"),
+				Duration: 30 * time.Minute, Granularity: 30 * time.Minute,
+			},
+			want: []string{
+				"2024-03-31 01:00 +01:00", "2024-03-31 01:30 +01:00",
+				"2024-03-31 03:00 +02:00", "2024-03-31 03:30 +02:00",
+			},
+		},
+		{
+			name: "fall back",
+			req: Request{
+				From: at("2024-10-27 00:00"), To: at("2024-10-28 00:00"),
+				Duration: time.Hour, Granularity: time.Hour,
+			},
+			want: []string{
+				"2024-10-27 01:00 +02:00", "2024-10-27 02:00 +02:00",
+				"2024-10-27 02:00 +01:00", "2024-10-27 03:00 +01:00",
+			},
+		},
+		{
+			name: "closed day",
+			req: Request{
+				From: at("2024-03-27 00:00"), To: at("2024-03-28 00:00"),
+				Duration: 30 * time.Minute,
+			},
+			want: []string{},
+		},
+		{
+			name: "closed exception",
+			req: Request{
+				From: at("2024-04-01 00:00"), To: at("2024-04-02 00:00"),
+				Duration: 30 * time.Minute,
+			},
+			want: []string{},
+		},
+		{
+			name: "empty range",
+			req: Request{
+				From: at("2024-03-25 10:00"), To: at("2024-03-25 10:00"),
+				Duration: 30 * time.Minute,
+			},
+			want: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.req.Timetable = testTimetable()
+
+			slots, err := Slots(c.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := starts(slots); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Slots() = %v, want %v", got, c.want)
+			}
+
+			for _, slot := range slots {
+				if got := slot.End.Sub(slot.Start); got != c.req.Duration {
+					t.Errorf("slot at %s lasts %s, want %s", slot.Start, got, c.req.Duration)
+				}
+			}
+		})
+	}
+}
+
+func TestSlotsErrors(t *testing.T) {
+	valid := Request{
+		Timetable: testTimetable(),
+		From:      at("2024-03-25 00:00"),
+		To:        at("2024-03-26 00:00"),
+		Duration:  30 * time.Minute,
+	}
+
+	cases := []struct {
+		name   string
+		change func(req *Request)
+	}{
+		{name: "no timetable", change: func(req *Request) { req.Timetable = nil }},
+		{name: "no duration", change: func(req *Request) { req.Duration = 0 }},
+		{name: "negative buffer", change: func(req *Request) { req.BufferAfter = -time.Minute }},
+		{name: "negative granularity", change: func(req *Request) { req.Granularity = -time.Minute }},
+		{name: "invalid timezone", change: func(req *Request) { req.Timetable.Timezone = "Nowhere/Nothing" }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := valid
+			req.Timetable = testTimetable()
+			c.change(&req)
+
+			if slots, err := Slots(req); err == nil {
+				t.Errorf("Slots() = %v, want an error", slots)
+			}
+		})
+	}
+}
+
+func TestSlotsByDay(t *testing.T) {
+	tt := testTimetable()
+
+	// Only Mondays have a timetable.
+	resolved := []string{}
+	resolve := func(date time.Time) (*timetablestypes.Timetable, error) {
+		resolved = append(resolved, date.Format("2006-01-02"))
+		if date.Weekday() != time.Monday {
+			return nil, nil
+		}
+
+		return tt, nil
+	}
+
+	slots, err := SlotsByDay(Request{
+		From:     at("2024-03-25 15:00"),
+		To:       at("2024-04-08 09:30"),
+		Duration: time.Hour,
+	}, rome, resolve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"2024-03-25 15:00 +01:00",
+		"2024-04-08 09:00 +02:00", "2024-04-08 09:15 +02:00",
+	}
+	if got := starts(slots); !reflect.DeepEqual(got, want) {
+		t.Errorf("SlotsByDay() = %v, want %v", got, want)
+	}
+
+	if len(resolved) != 15 || resolved[0] != "2024-03-25" || resolved[14] != "2024-04-08" {
+		t.Errorf("resolved the days %v", resolved)
+	}
+}
+
+func TestInsideTimetable(t *testing.T) {
+	tt := testTimetable()
+
+	cases := []struct {
+		name   string
+		start  time.Time
+		end    time.Time
+		inside bool
+	}{
+		{name: "inside", start: at("2024-03-25 09:00"), end: at("2024-03-25 12:00"), inside: true},
+		{name: "past the closing", start: at("2024-03-25 11:30"), end: at("2024-03-25 12:30")},
+		{name: "across two intervals", start: at("2024-03-25 11:00"), end: at("2024-03-25 14:30")},
+		{name: "closed day", start: at("2024-03-27 09:00"), end: at("2024-03-27 10:00")},
+		{name: "empty", start: at("2024-03-25 10:00"), end: at("2024-03-25 10:00")},
+		{name: "skipped hour", start: at("2024-03-31 01:30"), end: at("2024-03-31 03:30"), inside: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			inside, err := InsideTimetable(tt, c.start, c.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if inside != c.inside {
+				t.Errorf("InsideTimetable() = %t, want %t", inside, c.inside)
+			}
+		})
+	}
+}
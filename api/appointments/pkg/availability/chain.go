@@ -0,0 +1,148 @@
+package availability
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/schedule"
+	timetablestypes "github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+// Segment is a service in a chain of services booked one after the other.
+type Segment struct {
+	Duration     time.Duration
+	BufferBefore time.Duration
+	BufferAfter  time.Duration
+	// Busy are the times already taken for the service, as in Request.
+	Busy []interval.Interval
+	// Resolve returns the timetable of the service in effect on a day.
+	Resolve Resolver
+}
+
+// ChainSlot is a time when a whole chain can be booked: Segments are the
+// slots of its services.
+type ChainSlot struct {
+	Start    time.Time `json:"start" yaml:"start"`
+	End      time.Time `json:"end" yaml:"end"`
+	Segments []Slot    `json:"segments" yaml:"segments"`
+}
+
+// ChainRequest contains what is needed to compute the slots of a chain.
+type ChainRequest struct {
+	Segments []Segment
+	// From and To bound the start times of the chains, From included and
+	// To excluded.
+	From        time.Time
+	To          time.Time
+	Granularity time.Duration
+}
+
+// ChainOffsets returns when each segment starts, from the start of the
+// chain. A segment starts when the previous one ends, plus the buffer
+// after the previous one and the buffer before it.
+func ChainOffsets(segments []Segment) []time.Duration {
+	offsets := make([]time.Duration, len(segments))
+	for i := 1; i < len(segments); i++ {
+		prev := segments[i-1]
+		offsets[i] = offsets[i-1] + prev.Duration + prev.BufferAfter + segments[i].BufferBefore
+	}
+
+	return offsets
+}
+
+// ChainSlots returns the times when the whole chain can be booked, i.e.
+// each segment is inside the timetable of its service and none of them is
+// busy, sorted by start. Candidate starts are the slots of the first
+// segment.
+func ChainSlots(req ChainRequest, loc *time.Location) ([]ChainSlot, error) {
+	if len(req.Segments) == 0 {
+		return nil, fmt.Errorf("no segments provided")
+	}
+
+	for _, segment := range req.Segments {
+		if segment.Duration <= 0 {
+			return nil, fmt.Errorf("invalid duration provided")
+		}
+
+		if segment.BufferBefore < 0 || segment.BufferAfter < 0 {
+			return nil, fmt.Errorf("invalid buffers provided")
+		}
+	}
+
+	first := req.Segments[0]
+	candidates, err := SlotsByDay(Request{
+		From:         req.From,
+		To:           req.To,
+		Duration:     first.Duration,
+		BufferBefore: first.BufferBefore,
+		BufferAfter:  first.BufferAfter,
+		Granularity:  req.Granularity,
+		Busy:         first.Busy,
+	}, loc, first.Resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := ChainOffsets(req.Segments)
+	checkers := make([]*segmentChecker, len(req.Segments))
+	for i := range req.Segments {
+		checkers[i] = &segmentChecker{segment: req.Segments[i], loc: loc, timetables: map[string]*timetablestypes.Timetable{}}
+	}
+
+	slots := []ChainSlot{}
+	for _, candidate := range candidates {
+		chain := ChainSlot{Start: candidate.Start, Segments: []Slot{candidate}}
+
+		fits := true
+		for i := 1; i < len(req.Segments) && fits; i++ {
+			start := candidate.Start.Add(offsets[i])
+			end := start.Add(req.Segments[i].Duration)
+			if fits, err = checkers[i].fits(start, end); err != nil {
+				return nil, err
+			}
+
+			chain.Segments = append(chain.Segments, Slot{Start: start, End: end})
+		}
+
+		if !fits {
+			continue
+		}
+
+		chain.End = chain.Segments[len(chain.Segments)-1].End
+		slots = append(slots, chain)
+	}
+
+	return slots, nil
+}
+
+// segmentChecker tells whether a segment can take place at a given time,
+// remembering the timetables of the days it already saw.
+type segmentChecker struct {
+	segment    Segment
+	loc        *time.Location
+	timetables map[string]*timetablestypes.Timetable
+}
+
+func (c *segmentChecker) fits(start, end time.Time) (bool, error) {
+	if overlapsAny(c.segment.Busy, Occupied(start, end, c.segment.BufferBefore, c.segment.BufferAfter)) {
+		return false, nil
+	}
+
+	day := start.In(c.loc).Format(schedule.DateFormat)
+	tt, found := c.timetables[day]
+	if !found {
+		var err error
+		if tt, err = c.segment.Resolve(start.In(c.loc)); err != nil {
+			return false, err
+		}
+
+		c.timetables[day] = tt
+	}
+
+	if tt == nil {
+		return false, nil
+	}
+
+	return InsideTimetable(tt, start, end)
+}
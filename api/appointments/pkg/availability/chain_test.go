@@ -0,0 +1,120 @@
+package availability
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	timetablestypes "github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+func TestChainOffsets(t *testing.T) {
+	segments := []Segment{
+		{Duration: 30 * time.Minute, BufferAfter: 10 * time.Minute},
+		{Duration: time.Hour, BufferBefore: 5 * time.Minute},
+		{Duration: 15 * time.Minute, BufferBefore: 15 * time.Minute},
+	}
+
+	want := []time.Duration{0, 45 * time.Minute, 2 * time.Hour}
+	if got := ChainOffsets(segments); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChainOffsets() = %v, want %v", got, want)
+	}
+}
+
+func TestChainSlots(t *testing.T) {
+	tt := testTimetable()
+	always := func(time.Time) (*timetablestypes.Timetable, error) {
+		return tt, nil
+	}
+
+	// The second service is only provided in the mornings.
+	mornings := testTimetable()
+	mornings.Monday = mornings.Monday[:1]
+	onlyMornings := func(time.Time) (*timetablestypes.Timetable, error) {
+		return mornings, nil
+	}
+
+	cases := []struct {
+		name     string
+		segments []Segment
+		want     []string
+	}{
+		{
+			name: "whole chain inside the timetables",
+			segments: []Segment{
+				{Duration: 30 * time.Minute, Resolve: always},
+				{Duration: time.Hour, Resolve: onlyMornings},
+			},
+			want: []string{
+				"2024-03-25 09:00 +01:00", "2024-03-25 09:30 +01:00",
+				"2024-03-25 10:00 +01:00", "2024-03-25 10:30 +01:00",
+			},
+		},
+		{
+			name: "later segment busy",
+			segments: []Segment{
+				{Duration: 30 * time.Minute, Resolve: always},
+				{
+					Duration: time.Hour, Resolve: onlyMornings,
+					Busy: []interval.Interval{Occupied(at("2024-03-25 10:30"), at("2024-03-25 11:00"), 0, 0)},
+				},
+			},
+			want: []string{"2024-03-25 09:00 +01:00", "2024-03-25 10:30 +01:00"},
+		},
+		{
+			name: "buffers between segments",
+			segments: []Segment{
+				{Duration: 30 * time.Minute, BufferAfter: 15 * time.Minute, Resolve: always},
+				{Duration: time.Hour, BufferBefore: 15 * time.Minute, Resolve: onlyMornings},
+			},
+			want: []string{"2024-03-25 09:00 +01:00", "2024-03-25 09:30 +01:00", "2024-03-25 10:00 +01:00"},
+		},
+		{
+			name: "no timetable for a later segment",
+			segments: []Segment{
+				{Duration: 30 * time.Minute, Resolve: always},
+				{Duration: 30 * time.Minute, Resolve: func(time.Time) (*timetablestypes.Timetable, error) { return nil, nil }},
+			},
+			want: []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			slots, err := ChainSlots(ChainRequest{
+				Segments:    c.segments,
+				From:        at("2024-03-25 00:00"),
+				To:          at("2024-03-26 00:00"),
+				Granularity: 30 * time.Minute,
+			}, rome)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			offsets := ChainOffsets(c.segments)
+			for _, slot := range slots {
+				got = append(got, slot.Start.In(rome).Format(slotFormat))
+
+				if len(slot.Segments) != len(c.segments) {
+					t.Fatalf("chain at %s has %d segments", slot.Start, len(slot.Segments))
+				}
+
+				for i, segment := range slot.Segments {
+					if !segment.Start.Equal(slot.Start.Add(offsets[i])) {
+						t.Errorf("segment %d of the chain at %s starts at %s", i, slot.Start, segment.Start)
+					}
+				}
+
+				if !slot.End.Equal(slot.Segments[len(slot.Segments)-1].End) {
+					t.Errorf("chain at %s ends at %s", slot.Start, slot.End)
+				}
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("ChainSlots() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Package policy applies the cancellation and rescheduling policies of
+// services to bookings.
+package policy
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	servicestypes "github.com/asimpleidea/appoint/api/services/pkg/types"
+)
+
+// Decision is the outcome of applying a policy to a cancellation or a
+// reschedule.
+type Decision struct {
+	Allowed bool
+	Fee     float64
+	// Rule describes the rule that triggered the fee or the refusal, and is
+	// empty if none did.
+	Rule string
+}
+
+// Cancel applies the policy to the cancellation, at now, of a booking that
+// starts at start. price is the price of the service, which fees are a
+// percentage of. A nil policy allows everything for free.
+func Cancel(p *servicestypes.Policy, price float64, start, now time.Time) Decision {
+	if p == nil {
+		return Decision{Allowed: true}
+	}
+
+	return applyFee("cancellation", p.Cancellation, price, start, now)
+}
+
+// Reschedule applies the policy to moving, at now, a booking that starts at
+// start and was already moved reschedules times.
+func Reschedule(p *servicestypes.Policy, price float64, start, now time.Time, reschedules uint) Decision {
+	if p == nil {
+		return Decision{Allowed: true}
+	}
+
+	if p.MaxReschedules != nil && reschedules >= *p.MaxReschedules {
+		return Decision{
+			Allowed: false,
+			Rule:    fmt.Sprintf("at most %d reschedules", *p.MaxReschedules),
+		}
+	}
+
+	return applyFee("reschedule", p.Reschedule, price, start, now)
+}
+
+// applyFee returns the fee of the rule with the smallest window among the
+// ones that apply.
+func applyFee(name string, rules []servicestypes.FeeRule, price float64, start, now time.Time) Decision {
+	left := start.Sub(now)
+
+	var applied *servicestypes.FeeRule
+	for i, rule := range rules {
+		if left >= time.Duration(rule.WithinHours)*time.Hour {
+			continue
+		}
+
+		if applied == nil || rule.WithinHours < applied.WithinHours {
+			applied = &rules[i]
+		}
+	}
+
+	if applied == nil {
+		return Decision{Allowed: true}
+	}
+
+	return Decision{
+		Allowed: true,
+		Fee:     math.Round(price*applied.FeePercent) / 100,
+		Rule:    fmt.Sprintf("%s within %dh of the start: %g%% fee", name, applied.WithinHours, applied.FeePercent),
+	}
+}
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	servicestypes "github.com/asimpleidea/appoint/api/services/pkg/types"
+)
+
+func uintPtr(value uint) *uint {
+	return &value
+}
+
+// tieredPolicy charges 20% within 48 hours of the start and 50% within 24
+// hours, for both cancellations and reschedules, which are allowed twice.
+func tieredPolicy() *servicestypes.Policy {
+	rules := []servicestypes.FeeRule{
+		{WithinHours: 48, FeePercent: 20},
+		{WithinHours: 24, FeePercent: 50},
+	}
+
+	return &servicestypes.Policy{
+		Cancellation:   rules,
+		Reschedule:     rules,
+		MaxReschedules: uintPtr(2),
+	}
+}
+
+func TestCancel(t *testing.T) {
+	start := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		policy *servicestypes.Policy
+		price  float64
+		left   time.Duration
+		want   Decision
+	}{
+		{
+			name:  "no policy",
+			price: 80,
+			left:  time.Hour,
+			want:  Decision{Allowed: true},
+		},
+		{
+			name:   "before every window",
+			policy: tieredPolicy(),
+			price:  80,
+			left:   72 * time.Hour,
+			want:   Decision{Allowed: true},
+		},
+		{
+			name:   "exactly at the start of a window",
+			policy: tieredPolicy(),
+			price:  80,
+			left:   48 * time.Hour,
+			want:   Decision{Allowed: true},
+		},
+		{
+			name:   "inside the largest window",
+			policy: tieredPolicy(),
+			price:  80,
+			left:   30 * time.Hour,
+			want:   Decision{Allowed: true, Fee: 16, Rule: "cancellation within 48h of the start: 20% fee"},
+		},
+		{
+			name:   "the smallest window wins",
+			policy: tieredPolicy(),
+			price:  80,
+			left:   time.Hour,
+			want:   Decision{Allowed: true, Fee: 40, Rule: "cancellation within 24h of the start: 50% fee"},
+		},
+		{
+			name:   "after the start",
+			policy: tieredPolicy(),
+			price:  80,
+			left:   -time.Hour,
+			want:   Decision{Allowed: true, Fee: 40, Rule: "cancellation within 24h of the start: 50% fee"},
+		},
+		{
+			name:   "rounded to cents",
+			policy: tieredPolicy(),
+			price:  33.33,
+			left:   time.Hour,
+			want:   Decision{Allowed: true, Fee: 16.67, Rule: "cancellation within 24h of the start: 50% fee"},
+		},
+		{
+			name:   "no price",
+			policy: tieredPolicy(),
+			left:   time.Hour,
+			want:   Decision{Allowed: true, Rule: "cancellation within 24h of the start: 50% fee"},
+		},
+		{
+			name:   "only reschedule rules",
+			policy: &servicestypes.Policy{Reschedule: tieredPolicy().Reschedule},
+			price:  80,
+			left:   time.Hour,
+			want:   Decision{Allowed: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Cancel(tc.policy, tc.price, start, start.Add(-tc.left))
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	start := time.Date(2024, time.March, 10, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name        string
+		policy      *servicestypes.Policy
+		left        time.Duration
+		reschedules uint
+		want        Decision
+	}{
+		{
+			name:        "no policy",
+			left:        time.Hour,
+			reschedules: 10,
+			want:        Decision{Allowed: true},
+		},
+		{
+			name:   "free before every window",
+			policy: tieredPolicy(),
+			left:   72 * time.Hour,
+			want:   Decision{Allowed: true},
+		},
+		{
+			name:        "fee below the limit",
+			policy:      tieredPolicy(),
+			left:        time.Hour,
+			reschedules: 1,
+			want:        Decision{Allowed: true, Fee: 50, Rule: "reschedule within 24h of the start: 50% fee"},
+		},
+		{
+			name:        "limit reached",
+			policy:      tieredPolicy(),
+			left:        72 * time.Hour,
+			reschedules: 2,
+			want:        Decision{Allowed: false, Rule: "at most 2 reschedules"},
+		},
+		{
+			name:        "no reschedules allowed",
+			policy:      &servicestypes.Policy{MaxReschedules: uintPtr(0)},
+			left:        72 * time.Hour,
+			reschedules: 0,
+			want:        Decision{Allowed: false, Rule: "at most 0 reschedules"},
+		},
+		{
+			name:        "no limit",
+			policy:      &servicestypes.Policy{Reschedule: tieredPolicy().Reschedule},
+			left:        72 * time.Hour,
+			reschedules: 100,
+			want:        Decision{Allowed: true},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Reschedule(tc.policy, 100, start, start.Add(-tc.left), tc.reschedules)
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package types
+
+import "time"
+
+// Booking is an appointment for a service, from Start included to End
+// excluded.
+type Booking struct {
+	ID        uint       `json:"id" yaml:"id"`
+	CreatedAt time.Time  `json:"created_at" yaml:"createdAt"`
+	UpdatedAt time.Time  `json:"updated_at" yaml:"updatedAt"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
+	ServiceID uint       `json:"service_id" yaml:"serviceId"`
+	Start     time.Time  `json:"start" yaml:"start"`
+	End       time.Time  `json:"end" yaml:"end"`
+	Customer  string     `json:"customer" yaml:"customer"`
+	Notes     string     `json:"notes,omitempty" yaml:"notes,omitempty"`
+	// SeriesID is set if the booking is an occurrence of a series.
+	SeriesID *uint `json:"series_id,omitempty" yaml:"seriesId,omitempty"`
+	// ChainID is set if the booking is part of a chain.
+	ChainID *uint `json:"chain_id,omitempty" yaml:"chainId,omitempty"`
+	// Reschedules is how many times the booking was moved and Fees is the
+	// total charged for moving or cancelling it.
+	Reschedules uint    `json:"reschedules,omitempty" yaml:"reschedules,omitempty"`
+	Fees        float64 `json:"fees,omitempty" yaml:"fees,omitempty"`
+	// Status is decided by the server: new bookings are requested or
+	// confirmed depending on their service.
+	Status BookingStatus `json:"status" yaml:"status"`
+}
+
+// Conflict tells which booking already takes the time of a new one. If
+// Held is true, BookingID is the ID of a hold.
+type Conflict struct {
+	Message   string    `json:"message" yaml:"message"`
+	BookingID uint      `json:"booking_id" yaml:"bookingId"`
+	Held      bool      `json:"held,omitempty" yaml:"held,omitempty"`
+	Start     time.Time `json:"start" yaml:"start"`
+	End       time.Time `json:"end" yaml:"end"`
+}
+
+// PolicyOutcome is the result of cancelling or rescheduling a booking under
+// the policy of its service.
+type PolicyOutcome struct {
+	Fee float64 `json:"fee" yaml:"fee"`
+	// Rule describes the rule that triggered the fee or the refusal.
+	Rule string `json:"rule,omitempty" yaml:"rule,omitempty"`
+	// PolicyServiceID is the service the policy comes from, which can be a
+	// parent of the one of the booking.
+	PolicyServiceID uint     `json:"policy_service_id,omitempty" yaml:"policyServiceId,omitempty"`
+	Booking         *Booking `json:"booking,omitempty" yaml:"booking,omitempty"`
+}
@@ -0,0 +1,22 @@
+package types
+
+import "time"
+
+// Chain is a booking of several services one after the other, e.g. a cut
+// followed by a colour. Each service has a booking of its own.
+type Chain struct {
+	ID        uint       `json:"id" yaml:"id"`
+	CreatedAt time.Time  `json:"created_at" yaml:"createdAt"`
+	UpdatedAt time.Time  `json:"updated_at" yaml:"updatedAt"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
+	// Start is when the first service starts.
+	Start    time.Time `json:"start" yaml:"start"`
+	End      time.Time `json:"end" yaml:"end"`
+	Customer string    `json:"customer" yaml:"customer"`
+	Notes    string    `json:"notes,omitempty" yaml:"notes,omitempty"`
+	// Services are the services to book, in order. They are only read when
+	// the chain is created.
+	Services []uint `json:"services,omitempty" yaml:"services,omitempty"`
+	// Bookings are the bookings of the services, in order.
+	Bookings []Booking `json:"bookings,omitempty" yaml:"bookings,omitempty"`
+}
@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+// ClassSession is a session of a service that many people attend at once,
+// each with an Attendee booking, up to Capacity.
+type ClassSession struct {
+	ID        uint      `json:"id" yaml:"id"`
+	CreatedAt time.Time `json:"created_at" yaml:"createdAt"`
+	UpdatedAt time.Time `json:"updated_at" yaml:"updatedAt"`
+	ServiceID uint      `json:"service_id" yaml:"serviceId"`
+	Start     time.Time `json:"start" yaml:"start"`
+	End       time.Time `json:"end" yaml:"end"`
+	// Capacity is the one of the service when the session was created.
+	Capacity  uint `json:"capacity" yaml:"capacity"`
+	Booked    uint `json:"booked" yaml:"booked"`
+	Available uint `json:"available" yaml:"available"`
+}
+
+// Attendee is a booking of a seat of a class session.
+type Attendee struct {
+	ID        uint      `json:"id" yaml:"id"`
+	CreatedAt time.Time `json:"created_at" yaml:"createdAt"`
+	SessionID uint      `json:"session_id" yaml:"sessionId"`
+	Customer  string    `json:"customer" yaml:"customer"`
+	Notes     string    `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
@@ -0,0 +1,18 @@
+package types
+
+import "time"
+
+// Hold keeps a slot of a service from being booked by others until it
+// expires, e.g. while a customer checks out. It can be confirmed into a
+// booking or released.
+type Hold struct {
+	ID        uint      `json:"id" yaml:"id"`
+	CreatedAt time.Time `json:"created_at" yaml:"createdAt"`
+	ServiceID uint      `json:"service_id" yaml:"serviceId"`
+	Start     time.Time `json:"start" yaml:"start"`
+	End       time.Time `json:"end" yaml:"end"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expiresAt"`
+	// TTL is how many seconds the hold lasts. It is only read when the
+	// hold is created.
+	TTL uint `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+}
@@ -0,0 +1,40 @@
+package types
+
+import "time"
+
+// Series is a recurring booking: the first occurrence goes from Start to
+// End and the next ones follow RRule, an RFC 5545 recurrence rule, at the
+// same wall clock time.
+type Series struct {
+	ID        uint       `json:"id" yaml:"id"`
+	CreatedAt time.Time  `json:"created_at" yaml:"createdAt"`
+	UpdatedAt time.Time  `json:"updated_at" yaml:"updatedAt"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
+	ServiceID uint       `json:"service_id" yaml:"serviceId"`
+	Start     time.Time  `json:"start" yaml:"start"`
+	End       time.Time  `json:"end" yaml:"end"`
+	RRule     string     `json:"rrule" yaml:"rrule"`
+	Customer  string     `json:"customer" yaml:"customer"`
+	Notes     string     `json:"notes,omitempty" yaml:"notes,omitempty"`
+	// Occurrences are only filled when the series is retrieved.
+	Occurrences []Occurrence `json:"occurrences,omitempty" yaml:"occurrences,omitempty"`
+}
+
+type OccurrenceStatus string
+
+const (
+	// OccurrenceScheduled is an occurrence booked at the time of the rule.
+	OccurrenceScheduled OccurrenceStatus = "scheduled"
+	// OccurrenceMoved is an occurrence booked at another time.
+	OccurrenceMoved OccurrenceStatus = "moved"
+	// OccurrenceSkipped is an occurrence that will not take place.
+	OccurrenceSkipped OccurrenceStatus = "skipped"
+)
+
+// Occurrence is an instance of a series. At is when the rule places it,
+// while Booking tells when it actually takes place.
+type Occurrence struct {
+	At      time.Time        `json:"at" yaml:"at"`
+	Status  OccurrenceStatus `json:"status" yaml:"status"`
+	Booking *Booking         `json:"booking" yaml:"booking"`
+}
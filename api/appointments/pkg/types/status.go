@@ -0,0 +1,65 @@
+package types
+
+import "time"
+
+// BookingStatus is the state of a booking.
+type BookingStatus string
+
+const (
+	// BookingRequested is a booking waiting for the staff to confirm or
+	// decline it.
+	BookingRequested BookingStatus = "requested"
+	BookingConfirmed BookingStatus = "confirmed"
+	BookingDeclined  BookingStatus = "declined"
+	BookingCancelled BookingStatus = "cancelled"
+	// BookingCompleted is a booking that took place.
+	BookingCompleted BookingStatus = "completed"
+	// BookingNoShow is a booking whose customer did not show up.
+	BookingNoShow BookingStatus = "no-show"
+)
+
+// bookingTransitions are the statuses each status can become.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingRequested: {BookingConfirmed, BookingDeclined, BookingCancelled},
+	BookingConfirmed: {BookingCancelled, BookingCompleted, BookingNoShow},
+}
+
+// IsValid tells whether the status is one of the known ones.
+func (s BookingStatus) IsValid() bool {
+	switch s {
+	case BookingRequested, BookingConfirmed, BookingDeclined,
+		BookingCancelled, BookingCompleted, BookingNoShow:
+		return true
+	default:
+		return false
+	}
+}
+
+// CanBecome tells whether a booking with the status can be moved to the
+// other one.
+func (s BookingStatus) CanBecome(other BookingStatus) bool {
+	for _, allowed := range bookingTransitions[s] {
+		if allowed == other {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TakesTime tells whether a booking with the status keeps others from
+// booking its time.
+func (s BookingStatus) TakesTime() bool {
+	return s != BookingDeclined && s != BookingCancelled
+}
+
+// BookingTransition is a change of the status of a booking. The first one
+// has no From, and tells when the booking was created and by whom.
+type BookingTransition struct {
+	ID        uint          `json:"id" yaml:"id"`
+	CreatedAt time.Time     `json:"created_at" yaml:"createdAt"`
+	BookingID uint          `json:"booking_id" yaml:"bookingId"`
+	From      BookingStatus `json:"from,omitempty" yaml:"from,omitempty"`
+	To        BookingStatus `json:"to" yaml:"to"`
+	Actor     string        `json:"actor,omitempty" yaml:"actor,omitempty"`
+}
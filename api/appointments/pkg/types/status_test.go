@@ -0,0 +1,70 @@
+package types
+
+import "testing"
+
+func TestBookingStatusCanBecome(t *testing.T) {
+	statuses := []BookingStatus{
+		BookingRequested, BookingConfirmed, BookingDeclined,
+		BookingCancelled, BookingCompleted, BookingNoShow,
+	}
+
+	allowed := map[BookingStatus]map[BookingStatus]bool{
+		BookingRequested: {BookingConfirmed: true, BookingDeclined: true, BookingCancelled: true},
+		BookingConfirmed: {BookingCancelled: true, BookingCompleted: true, BookingNoShow: true},
+	}
+
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got := from.CanBecome(to); got != allowed[from][to] {
+				t.Errorf("%s can become %s: got %t, want %t", from, to, got, allowed[from][to])
+			}
+		}
+	}
+
+	if BookingStatus("").CanBecome(BookingConfirmed) {
+		t.Error("a booking with no status can become confirmed")
+	}
+}
+
+func TestBookingStatusIsValid(t *testing.T) {
+	cases := []struct {
+		status BookingStatus
+		want   bool
+	}{
+		{status: BookingRequested, want: true},
+		{status: BookingConfirmed, want: true},
+		{status: BookingDeclined, want: true},
+		{status: BookingCancelled, want: true},
+		{status: BookingCompleted, want: true},
+		{status: BookingNoShow, want: true},
+		{status: "", want: false},
+		{status: "Confirmed", want: false},
+		{status: "noshow", want: false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.status.IsValid(); got != tc.want {
+			t.Errorf("%q is valid: got %t, want %t", tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestBookingStatusTakesTime(t *testing.T) {
+	cases := []struct {
+		status BookingStatus
+		want   bool
+	}{
+		{status: BookingRequested, want: true},
+		{status: BookingConfirmed, want: true},
+		{status: BookingCompleted, want: true},
+		{status: BookingNoShow, want: true},
+		{status: BookingDeclined, want: false},
+		{status: BookingCancelled, want: false},
+	}
+
+	for _, tc := range cases {
+		if got := tc.status.TakesTime(); got != tc.want {
+			t.Errorf("%s takes time: got %t, want %t", tc.status, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package types
+
+import "time"
+
+type WaitlistStatus string
+
+const (
+	// WaitlistWaiting is an entry waiting for a slot.
+	WaitlistWaiting WaitlistStatus = "waiting"
+	// WaitlistOffered is an entry that was offered a slot and did not
+	// answer yet.
+	WaitlistOffered WaitlistStatus = "offered"
+	// WaitlistAccepted is an entry whose offer was turned into a booking.
+	WaitlistAccepted WaitlistStatus = "accepted"
+	// WaitlistDeclined is an entry whose offer was declined.
+	WaitlistDeclined WaitlistStatus = "declined"
+	// WaitlistExpired is an entry whose offer was not answered in time.
+	WaitlistExpired WaitlistStatus = "expired"
+)
+
+// WaitlistEntry is a customer waiting for a slot of a service between From
+// and To. Entries are offered freed slots in the order they were created.
+type WaitlistEntry struct {
+	ID        uint           `json:"id" yaml:"id"`
+	CreatedAt time.Time      `json:"created_at" yaml:"createdAt"`
+	UpdatedAt time.Time      `json:"updated_at" yaml:"updatedAt"`
+	ServiceID uint           `json:"service_id" yaml:"serviceId"`
+	From      time.Time      `json:"from" yaml:"from"`
+	To        time.Time      `json:"to" yaml:"to"`
+	Customer  string         `json:"customer" yaml:"customer"`
+	Notes     string         `json:"notes,omitempty" yaml:"notes,omitempty"`
+	Status    WaitlistStatus `json:"status" yaml:"status"`
+	Offer     *WaitlistOffer `json:"offer,omitempty" yaml:"offer,omitempty"`
+	// BookingID is set once the offer is accepted.
+	BookingID *uint `json:"booking_id,omitempty" yaml:"bookingId,omitempty"`
+}
+
+// WaitlistOffer is a slot held for an entry until ExpiresAt.
+type WaitlistOffer struct {
+	HoldID    uint      `json:"hold_id" yaml:"holdId"`
+	Start     time.Time `json:"start" yaml:"start"`
+	End       time.Time `json:"end" yaml:"end"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expiresAt"`
+}
+
+type WaitlistEventType string
+
+const (
+	WaitlistOfferMade    WaitlistEventType = "offer.made"
+	WaitlistOfferExpired WaitlistEventType = "offer.expired"
+)
+
+// WaitlistEvent is sent to the notification hooks when something happens
+// to an entry.
+type WaitlistEvent struct {
+	Type  WaitlistEventType `json:"type" yaml:"type"`
+	At    time.Time         `json:"at" yaml:"at"`
+	Entry *WaitlistEntry    `json:"entry" yaml:"entry"`
+}
@@ -0,0 +1,242 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Decode reads an iCalendar stream and returns its top-level component,
+// usually a VCALENDAR.
+func Decode(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := []*Component{}
+	var root *Component
+
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		switch prop.Name {
+		case "BEGIN":
+			stack = append(stack, &Component{Name: strings.ToUpper(prop.Value)})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.Value)
+			}
+
+			done := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			if len(stack) == 0 {
+				root = done
+				continue
+			}
+
+			parent := stack[len(stack)-1]
+			parent.Components = append(parent.Components, *done)
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property outside of any component", i+1)
+			}
+
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("component %s is not closed", stack[len(stack)-1].Name)
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("no component found")
+	}
+
+	return root, nil
+}
+
+// Get returns the first property with the provided name, if any.
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+
+	return nil
+}
+
+// GetAll returns all the properties with the provided name.
+func (c *Component) GetAll(name string) []Property {
+	props := []Property{}
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			props = append(props, prop)
+		}
+	}
+
+	return props
+}
+
+// Time parses the value of a DATE or DATE-TIME property. Dates are returned
+// at midnight and floating date-times, i.e. without time zone, in loc. The
+// returned bool tells whether the value is a date only.
+func (p *Property) Time(loc *time.Location) (time.Time, bool, error) {
+	return p.parseTime(p.Value, loc)
+}
+
+// Times parses a property that contains comma separated DATE or DATE-TIME
+// values, e.g. EXDATE.
+func (p *Property) Times(loc *time.Location) ([]time.Time, error) {
+	times := []time.Time{}
+	for _, value := range strings.Split(p.Value, ",") {
+		t, _, err := p.parseTime(value, loc)
+		if err != nil {
+			return nil, err
+		}
+
+		times = append(times, t)
+	}
+
+	return times, nil
+}
+
+func (p *Property) parseTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if tzid, exists := p.Params["TZID"]; exists {
+		tzLoc, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown TZID %s: %w", tzid, err)
+		}
+
+		loc = tzLoc
+	}
+
+	if p.Params["VALUE"] == "DATE" || len(value) == len(DateFormat) {
+		t, err := time.ParseInLocation(DateFormat, value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(UTCDateTimeFormat, value)
+		return t, false, err
+	}
+
+	t, err := time.ParseInLocation(DateTimeFormat, value, loc)
+	return t, false, err
+}
+
+// Unescape reverts Escape.
+func Unescape(text string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+
+		i++
+		switch text[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+
+	return b.String()
+}
+
+// unfold reads the content lines, joining the ones that were folded.
+func unfold(r io.Reader) ([]string, error) {
+	lines := []string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+func parseLine(line string) (Property, error) {
+	prop := Property{}
+
+	// The value starts after the first colon that is not inside quotes.
+	inQuotes, colon := false, -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ':':
+			if !inQuotes {
+				colon = i
+			}
+		}
+	}
+
+	if colon < 0 {
+		return prop, fmt.Errorf("invalid content line")
+	}
+
+	prop.Value = line[colon+1:]
+
+	parts := splitOutsideQuotes(line[:colon], ';')
+	prop.Name = strings.ToUpper(parts[0])
+	if prop.Name == "" {
+		return prop, fmt.Errorf("invalid content line: no name")
+	}
+
+	for _, param := range parts[1:] {
+		nameValue := strings.SplitN(param, "=", 2)
+		if len(nameValue) != 2 {
+			return prop, fmt.Errorf("invalid parameter %s", param)
+		}
+
+		if prop.Params == nil {
+			prop.Params = map[string]string{}
+		}
+
+		prop.Params[strings.ToUpper(nameValue[0])] = strings.Trim(nameValue[1], `"`)
+	}
+
+	return prop, nil
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	parts := []string{}
+	inQuotes, start := false, 0
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
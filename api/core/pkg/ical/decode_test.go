@@ -0,0 +1,113 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	stream := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:holiday-1",
+		"SUMMARY:Tag der Deutschen Einheit\\, Feiertag",
+		"DTSTART;VALUE=DATE:20241003",
+		"DESCRIPTION:a long description that was folded by the producer of the ",
+		" calendar",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:meeting",
+		"DTSTART;TZID=\"Europe/Rome\":20240331T093000",
+		"EXDATE:20240407T073000Z,20240414T073000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	calendar, err := Decode(strings.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calendar.Name != "VCALENDAR" || len(calendar.Components) != 2 {
+		t.Fatalf("got %s with %d components", calendar.Name, len(calendar.Components))
+	}
+
+	holiday := calendar.Components[0]
+	if got := Unescape(holiday.Get("SUMMARY").Value); got != "Tag der Deutschen Einheit, Feiertag" {
+		t.Errorf("SUMMARY = %q", got)
+	}
+
+	if got := holiday.Get("DESCRIPTION").Value; got != "a long description that was folded by the producer of the calendar" {
+		t.Errorf("DESCRIPTION = %q", got)
+	}
+
+	date, isDate, err := holiday.Get("DTSTART").Time(time.UTC)
+	if err != nil || !isDate || !date.Equal(time.Date(2024, time.October, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("DTSTART = %s, %t, %v", date, isDate, err)
+	}
+
+	meeting := calendar.Components[1]
+	start, isDate, err := meeting.Get("DTSTART").Time(time.UTC)
+	if err != nil || isDate || start.Location().String() != "Europe/Rome" ||
+		!start.Equal(time.Date(2024, time.March, 31, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("DTSTART = %s, %t, %v", start, isDate, err)
+	}
+
+	excluded, err := meeting.Get("EXDATE").Times(time.UTC)
+	if err != nil || len(excluded) != 2 || !excluded[1].Equal(time.Date(2024, time.April, 14, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("EXDATE = %v, %v", excluded, err)
+	}
+
+	if all := meeting.GetAll("EXDATE"); len(all) != 1 {
+		t.Errorf("GetAll(EXDATE) returned %d properties", len(all))
+	}
+
+	if meeting.Get("SUMMARY") != nil {
+		t.Errorf("got a SUMMARY that does not exist")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, stream := range []string{
+		"",
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nEND:VEVENT\r\n",
+		"SUMMARY:outside\r\n",
+	} {
+		if component, err := Decode(strings.NewReader(stream)); err == nil {
+			t.Errorf("Decode(%q) = %v, want an error", stream, component)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	calendar := &Component{Name: "VCALENDAR"}
+	event := Component{Name: "VEVENT"}
+	summary := "Ferragosto; chiuso, " + strings.Repeat("àèìòù ", 20)
+	event.Add("SUMMARY", Escape(summary), nil)
+	calendar.Components = append(calendar.Components, event)
+
+	buf := &bytes.Buffer{}
+	if err := calendar.Encode(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("line longer than %d octets: %q", maxLineLength, line)
+		}
+	}
+
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := Unescape(decoded.Components[0].Get("SUMMARY").Value); got != summary {
+		t.Errorf("SUMMARY = %q, want %q", got, summary)
+	}
+}
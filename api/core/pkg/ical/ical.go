@@ -0,0 +1,116 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// DateTimeFormat is the format of local date-times, i.e. the ones used
+	// with a TZID parameter.
+	DateTimeFormat string = "20060102T150405"
+	// UTCDateTimeFormat is the format of UTC date-times.
+	UTCDateTimeFormat string = "20060102T150405Z"
+	// DateFormat is the format of dates.
+	DateFormat string = "20060102"
+
+	maxLineLength int    = 75
+	lineEnding    string = "\r\n"
+)
+
+// Component is an iCalendar component, e.g. VCALENDAR or VEVENT, as defined
+// in RFC 5545.
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []Component
+}
+
+// Property is a content line of a component.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Add appends a property to the component.
+func (c *Component) Add(name, value string, params map[string]string) {
+	c.Properties = append(c.Properties, Property{
+		Name:   name,
+		Params: params,
+		Value:  value,
+	})
+}
+
+// Encode writes the component, and all of its sub-components, to w.
+func (c *Component) Encode(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	c.encode(buf)
+
+	return buf.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+
+	for _, prop := range c.Properties {
+		line := prop.Name
+
+		paramNames := make([]string, 0, len(prop.Params))
+		for name := range prop.Params {
+			paramNames = append(paramNames, name)
+		}
+		sort.Strings(paramNames)
+
+		for _, name := range paramNames {
+			line += fmt.Sprintf(";%s=%s", name, prop.Params[name])
+		}
+
+		writeLine(w, line+":"+prop.Value)
+	}
+
+	for _, sub := range c.Components {
+		sub.encode(w)
+	}
+
+	writeLine(w, "END:"+c.Name)
+}
+
+// writeLine writes a content line, folding it so that no line is longer
+// than 75 octets, without breaking UTF-8 sequences.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+
+		w.WriteString(line[:cut] + lineEnding)
+		line = line[cut:]
+
+		// Continuation lines start with a space, which counts as well.
+		w.WriteString(" ")
+		limit = maxLineLength - 1
+	}
+
+	w.WriteString(line + lineEnding)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// Escape escapes text values.
+func Escape(text string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(text)
+}
@@ -0,0 +1,430 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ part of a recurrence rule.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a BYDAY value, e.g. -1SU for the last Sunday. N is zero
+// when all the weekdays of the period are meant.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Recurrence is an RFC 5545 recurrence rule, as found in RRULE. Only FREQ,
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH are supported, and
+// weeks always start on Monday.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+
+	// untilIsDate is true when UNTIL is a date, which includes the whole
+	// day.
+	untilIsDate bool
+}
+
+// ParseRecurrence parses the value of an RRULE property.
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1}
+
+	for _, part := range strings.Split(strings.TrimSpace(rule), ";") {
+		nameValue := strings.SplitN(part, "=", 2)
+		if len(nameValue) != 2 {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		name, value := strings.ToUpper(nameValue[0]), strings.ToUpper(nameValue[1])
+		switch name {
+		case "FREQ":
+			switch freq := Frequency(value); freq {
+			case Daily, Weekly, Monthly, Yearly:
+				r.Freq = freq
+			default:
+				return nil, fmt.Errorf("unsupported frequency %s", value)
+			}
+		case "INTERVAL", "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid %s %s", name, value)
+			}
+
+			if name == "INTERVAL" {
+				r.Interval = n
+			} else {
+				r.Count = n
+			}
+		case "UNTIL":
+			until, isDate, err := (&Property{Value: value}).Time(time.UTC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %s: %w", value, err)
+			}
+
+			r.Until, r.untilIsDate = &until, isDate
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				if len(day) < 2 {
+					return nil, fmt.Errorf("invalid BYDAY %s", day)
+				}
+
+				weekday, err := parseWeekdayCode(day[len(day)-2:])
+				if err != nil {
+					return nil, err
+				}
+
+				n := 0
+				if ordinal := day[:len(day)-2]; ordinal != "" {
+					if n, err = strconv.Atoi(ordinal); err != nil || n == 0 {
+						return nil, fmt.Errorf("invalid BYDAY %s", day)
+					}
+				}
+
+				r.ByDay = append(r.ByDay, WeekdayNum{Weekday: weekday, N: n})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %s", day)
+				}
+
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(month)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("invalid BYMONTH %s", month)
+				}
+
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, fmt.Errorf("unsupported WKST %s", value)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("no frequency provided")
+	}
+
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL cannot be used together")
+	}
+
+	return r, nil
+}
+
+// String returns the rule in the RRULE format.
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+
+	if r.Until != nil {
+		if r.untilIsDate {
+			parts = append(parts, "UNTIL="+r.Until.Format(DateFormat))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format(UTCDateTimeFormat))
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = WeekdayCode(day.Weekday)
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns the occurrences of the rule that start from start,
+// which is the DTSTART of the event, and before end. All of them have the
+// same wall clock time as start, in its location.
+func (r *Recurrence) Occurrences(start, end time.Time) []time.Time {
+	loc := start.Location()
+	hour, min, sec := start.Clock()
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	var until *time.Time
+	if r.Until != nil {
+		limit := *r.Until
+		if r.untilIsDate {
+			limit = time.Date(limit.Year(), limit.Month(), limit.Day(), 23, 59, 59, 0, loc)
+		}
+
+		until = &limit
+	}
+
+	occurrences := []time.Time{}
+	firstPeriod := r.periodStart(start)
+	for period := 0; ; period += interval {
+		periodStart := r.addPeriods(firstPeriod, period)
+		if periodStart.After(end) {
+			return occurrences
+		}
+
+		for _, day := range r.candidates(periodStart, start) {
+			occurrence := time.Date(day.Year(), day.Month(), day.Day(), hour, min, sec, 0, loc)
+			if occurrence.Before(start) {
+				continue
+			}
+
+			if !occurrence.Before(end) || (until != nil && occurrence.After(*until)) {
+				return occurrences
+			}
+
+			occurrences = append(occurrences, occurrence)
+			if r.Count > 0 && len(occurrences) == r.Count {
+				return occurrences
+			}
+		}
+	}
+}
+
+// periodStart returns the first day of the period that contains t, at
+// midnight UTC.
+func (r *Recurrence) periodStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch r.Freq {
+	case Weekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Monthly:
+		return day.AddDate(0, 0, 1-day.Day())
+	case Yearly:
+		return time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+func (r *Recurrence) addPeriods(t time.Time, n int) time.Time {
+	switch r.Freq {
+	case Weekly:
+		return t.AddDate(0, 0, 7*n)
+	case Monthly:
+		return t.AddDate(0, n, 0)
+	case Yearly:
+		return t.AddDate(n, 0, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// candidates returns the sorted days of the period that match the rule.
+func (r *Recurrence) candidates(periodStart, start time.Time) []time.Time {
+	days := []time.Time{}
+
+	switch r.Freq {
+	case Daily:
+		days = r.filter([]time.Time{periodStart})
+	case Weekly:
+		week := []time.Time{}
+		for i := 0; i < 7; i++ {
+			day := periodStart.AddDate(0, 0, i)
+			if len(r.ByDay) > 0 || day.Weekday() == start.Weekday() {
+				week = append(week, day)
+			}
+		}
+
+		days = r.filter(week)
+	case Monthly:
+		days = r.expand(daysBetween(periodStart, periodStart.AddDate(0, 1, 0)), start)
+	case Yearly:
+		switch {
+		case len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) == 0:
+			day := time.Date(periodStart.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			if day.Day() == start.Day() {
+				days = append(days, day)
+			}
+		case len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0:
+			// Ordinals of BYDAY are relative to the year.
+			days = r.expand(daysBetween(periodStart, periodStart.AddDate(1, 0, 0)), start)
+		default:
+			for month := periodStart; month.Year() == periodStart.Year(); month = month.AddDate(0, 1, 0) {
+				days = append(days, r.expand(daysBetween(month, month.AddDate(0, 1, 0)), start)...)
+			}
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+
+	return days
+}
+
+// expand selects the days of a month or year according to BYMONTHDAY and
+// BYDAY, or the same day of the month of start if there are none.
+func (r *Recurrence) expand(days []time.Time, start time.Time) []time.Time {
+	days = r.filter(days)
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		selected := []time.Time{}
+		for _, day := range days {
+			if day.Day() == start.Day() {
+				selected = append(selected, day)
+			}
+		}
+
+		return selected
+	}
+
+	if len(r.ByDay) == 0 {
+		return days
+	}
+
+	selected := []time.Time{}
+	for _, byDay := range r.ByDay {
+		matching := []time.Time{}
+		for _, day := range days {
+			if day.Weekday() == byDay.Weekday {
+				matching = append(matching, day)
+			}
+		}
+
+		switch {
+		case byDay.N == 0:
+			selected = append(selected, matching...)
+		case byDay.N > 0 && byDay.N <= len(matching):
+			selected = append(selected, matching[byDay.N-1])
+		case byDay.N < 0 && -byDay.N <= len(matching):
+			selected = append(selected, matching[len(matching)+byDay.N])
+		}
+	}
+
+	return selected
+}
+
+// filter removes the days that are not in BYMONTH and BYMONTHDAY, and,
+// unless BYDAY has ordinals, the ones with other weekdays.
+func (r *Recurrence) filter(days []time.Time) []time.Time {
+	filtered := []time.Time{}
+
+	for _, day := range days {
+		if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, day.Month()) {
+			continue
+		}
+
+		if len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, day) {
+			continue
+		}
+
+		if r.Freq == Daily || r.Freq == Weekly {
+			if len(r.ByDay) > 0 && !matchesWeekday(r.ByDay, day.Weekday()) {
+				continue
+			}
+		}
+
+		filtered = append(filtered, day)
+	}
+
+	return filtered
+}
+
+func daysBetween(from, to time.Time) []time.Time {
+	days := []time.Time{}
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	return days
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesMonthDay(monthDays []int, day time.Time) bool {
+	daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	for _, monthDay := range monthDays {
+		if monthDay == day.Day() || (monthDay < 0 && daysInMonth+monthDay+1 == day.Day()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesWeekday(days []WeekdayNum, weekday time.Weekday) bool {
+	for _, day := range days {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseWeekdayCode(code string) (time.Weekday, error) {
+	for i, weekdayCode := range weekdayCodes {
+		if code == weekdayCode {
+			return time.Weekday(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid weekday %s", code)
+}
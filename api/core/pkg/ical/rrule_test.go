@@ -0,0 +1,149 @@
+package ical
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOccurrences(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, time.March, 4, 9, 30, 0, 0, rome) // a Monday
+	end := start.AddDate(1, 0, 0)
+
+	cases := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "daily count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: start,
+			want:  []string{"2024-03-04 09:30", "2024-03-05 09:30", "2024-03-06 09:30"},
+		},
+		{
+			name:  "weekly interval count",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;COUNT=3",
+			start: start,
+			want:  []string{"2024-03-04 09:30", "2024-03-18 09:30", "2024-04-01 09:30"},
+		},
+		{
+			name:  "weekly byday count",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5",
+			start: start,
+			want: []string{
+				"2024-03-04 09:30", "2024-03-06 09:30", "2024-03-08 09:30",
+				"2024-03-11 09:30", "2024-03-13 09:30",
+			},
+		},
+		{
+			name:  "byday before start in the first week",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+			start: start.AddDate(0, 0, 2), // Wednesday
+			want:  []string{"2024-03-07 09:30", "2024-03-11 09:30", "2024-03-14 09:30"},
+		},
+		{
+			name:  "until date includes the whole day",
+			rule:  "FREQ=WEEKLY;UNTIL=20240318",
+			start: start,
+			want:  []string{"2024-03-04 09:30", "2024-03-11 09:30", "2024-03-18 09:30"},
+		},
+		{
+			name:  "until date-time",
+			rule:  "FREQ=WEEKLY;UNTIL=20240318T080000Z",
+			start: start,
+			want:  []string{"2024-03-04 09:30", "2024-03-11 09:30"},
+		},
+		{
+			name:  "monthly last sunday",
+			rule:  "FREQ=MONTHLY;BYDAY=-1SU;COUNT=3",
+			start: start,
+			want:  []string{"2024-03-31 09:30", "2024-04-28 09:30", "2024-05-26 09:30"},
+		},
+		{
+			name:  "monthly second tuesday",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU;COUNT=2",
+			start: start,
+			want:  []string{"2024-03-12 09:30", "2024-04-09 09:30"},
+		},
+		{
+			name:  "yearly bymonth bymonthday",
+			rule:  "FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=25",
+			start: start,
+			want:  []string{"2024-12-25 09:30"},
+		},
+		{
+			name:  "wall clock kept across daylight saving time",
+			rule:  "FREQ=WEEKLY;COUNT=2",
+			start: time.Date(2024, time.March, 28, 9, 30, 0, 0, rome),
+			want:  []string{"2024-03-28 09:30", "2024-04-04 09:30"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rule, err := ParseRecurrence(c.rule)
+			if err != nil {
+				t.Fatalf("ParseRecurrence(%q) returned %v", c.rule, err)
+			}
+
+			got := []string{}
+			for _, occurrence := range rule.Occurrences(c.start, end) {
+				if occurrence.Location() != rome {
+					t.Errorf("occurrence %s is not in the location of start", occurrence)
+				}
+
+				got = append(got, occurrence.Format("2006-01-02 15:04"))
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Occurrences() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseRecurrenceErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"COUNT=3",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240318",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=WEEKLY;WKST=SU",
+		"FREQ=DAILY;BYHOUR=9",
+	} {
+		if parsed, err := ParseRecurrence(rule); err == nil {
+			t.Errorf("ParseRecurrence(%q) = %v, want an error", rule, parsed)
+		}
+	}
+}
+
+func TestRecurrenceString(t *testing.T) {
+	for _, rule := range []string{
+		"FREQ=DAILY;COUNT=3",
+		"FREQ=WEEKLY;INTERVAL=2;UNTIL=20240318;BYDAY=MO,WE",
+		"FREQ=WEEKLY;UNTIL=20240318T080000Z",
+		"FREQ=MONTHLY;BYDAY=-1SU",
+		"FREQ=YEARLY;BYMONTHDAY=25;BYMONTH=12",
+	} {
+		parsed, err := ParseRecurrence(rule)
+		if err != nil {
+			t.Fatalf("ParseRecurrence(%q) returned %v", rule, err)
+		}
+
+		if got := parsed.String(); got != rule {
+			t.Errorf("String() = %q, want %q", got, rule)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+var weekdayCodes = [7]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// WeekdayCode returns the two letters code of the weekday, e.g. MO.
+func WeekdayCode(weekday time.Weekday) string {
+	return weekdayCodes[weekday]
+}
+
+// Timezone returns a VTIMEZONE component for loc, with yearly rules that
+// are derived from the transitions happening in the provided year.
+func Timezone(loc *time.Location, year int) Component {
+	tz := Component{Name: "VTIMEZONE"}
+	tz.Add("TZID", loc.String(), nil)
+
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	end := start.AddDate(1, 0, 0)
+
+	transitions := []time.Time{}
+	for t := start; t.Before(end); t = t.Add(time.Hour) {
+		if offsetOf(t) != offsetOf(t.Add(time.Hour)) {
+			transitions = append(transitions, findTransition(t, t.Add(time.Hour)))
+		}
+	}
+
+	if len(transitions) == 0 {
+		name, offset := start.Zone()
+
+		standard := Component{Name: "STANDARD"}
+		standard.Add("DTSTART", "19700101T000000", nil)
+		standard.Add("TZOFFSETFROM", formatOffset(offset), nil)
+		standard.Add("TZOFFSETTO", formatOffset(offset), nil)
+		standard.Add("TZNAME", name, nil)
+		tz.Components = append(tz.Components, standard)
+
+		return tz
+	}
+
+	for _, transition := range transitions {
+		offsetFrom := offsetOf(transition.Add(-time.Second))
+		name, offsetTo := transition.Zone()
+
+		observance := Component{Name: "STANDARD"}
+		if transition.IsDST() {
+			observance.Name = "DAYLIGHT"
+		}
+
+		// The start of an observance is expressed with the offset that was
+		// in place right before it.
+		local := transition.In(time.FixedZone("", offsetFrom))
+		observance.Add("DTSTART", local.Format(DateTimeFormat), nil)
+		observance.Add("TZOFFSETFROM", formatOffset(offsetFrom), nil)
+		observance.Add("TZOFFSETTO", formatOffset(offsetTo), nil)
+		observance.Add("TZNAME", name, nil)
+		observance.Add("RRULE", fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%s%s",
+			local.Month(), nthWeekdayOrdinal(local), WeekdayCode(local.Weekday())), nil)
+		tz.Components = append(tz.Components, observance)
+	}
+
+	return tz
+}
+
+func offsetOf(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
+
+// findTransition returns the first instant between from and to that has
+// the same offset as to.
+func findTransition(from, to time.Time) time.Time {
+	for to.Sub(from) > time.Second {
+		middle := from.Add(to.Sub(from) / 2).Truncate(time.Second)
+		if offsetOf(middle) == offsetOf(to) {
+			to = middle
+		} else {
+			from = middle
+		}
+	}
+
+	return to
+}
+
+// nthWeekdayOrdinal returns the position of t's weekday in its month, as
+// used in BYDAY: -1 if it is the last one, its ordinal number otherwise.
+func nthWeekdayOrdinal(t time.Time) string {
+	if t.AddDate(0, 0, 7).Month() != t.Month() {
+		return "-1"
+	}
+
+	return fmt.Sprint((t.Day()-1)/7 + 1)
+}
+
+func formatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+}
@@ -0,0 +1,26 @@
+// Package text contains helpers for user-provided strings.
+package text
+
+import "unicode/utf8"
+
+// Truncate returns s with at most max characters, never splitting a
+// multi-byte character.
+func Truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	count := 0
+	for i := range s {
+		if count == max {
+			return s[:i]
+		}
+		count++
+	}
+
+	return s
+}
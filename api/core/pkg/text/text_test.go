@@ -0,0 +1,28 @@
+package text
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		name string
+		s    string
+		max  int
+		want string
+	}{
+		{name: "shorter", s: "Ostern", max: 10, want: "Ostern"},
+		{name: "exact", s: "Ostern", max: 6, want: "Ostern"},
+		{name: "ascii", s: "Ostermontag", max: 6, want: "Osterm"},
+		{name: "multi-byte kept whole", s: "Fronleichnam über", max: 14, want: "Fronleichnam ü"},
+		{name: "multi-byte at the end", s: "Tag der Einheit ö", max: 16, want: "Tag der Einheit "},
+		{name: "zero", s: "Neujahr", max: 0, want: ""},
+		{name: "empty", s: "", max: 5, want: ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Truncate(c.s, c.max); got != c.want {
+				t.Errorf("Truncate(%q, %d) = %q, want %q", c.s, c.max, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package database
+
+import "fmt"
+
+// Migrate creates the tables or brings them up to date.
+func (d *Database) Migrate() error {
+	if err := d.DB.AutoMigrate(&Service{}); err != nil {
+		return fmt.Errorf("cannot migrate tables: %w", err)
+	}
+
+	return nil
+}
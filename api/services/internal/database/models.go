@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/asimpleidea/appoint/api/services/pkg/types"
@@ -21,6 +22,13 @@ type Service struct {
 	Description string `gorm:"size:300"`
 	Price       sql.NullFloat64
 	PublicPrice bool
+	// Duration, BufferBefore and BufferAfter are in minutes.
+	Duration         uint           `gorm:"not null;default:0"`
+	BufferBefore     uint           `gorm:"not null;default:0"`
+	BufferAfter      uint           `gorm:"not null;default:0"`
+	Capacity         uint           `gorm:"not null;default:0"`
+	RequiresApproval bool           `gorm:"not null;default:false"`
+	Policy           sql.NullString `gorm:"type:jsonb"`
 }
 
 func (s *Service) TableName() string {
@@ -49,6 +57,23 @@ func (s *Service) toAPI() *types.Service {
 
 			return nil
 		}(),
-		PublicPrice: s.PublicPrice,
+		PublicPrice:      s.PublicPrice,
+		Duration:         s.Duration,
+		BufferBefore:     s.BufferBefore,
+		BufferAfter:      s.BufferAfter,
+		Capacity:         s.Capacity,
+		RequiresApproval: s.RequiresApproval,
+		Policy: func() *types.Policy {
+			if !s.Policy.Valid {
+				return nil
+			}
+
+			var policy types.Policy
+			if err := json.Unmarshal([]byte(s.Policy.String), &policy); err != nil {
+				return nil
+			}
+
+			return &policy
+		}(),
 	}
 }
@@ -12,6 +12,14 @@ import (
 const (
 	maxServiceNameLength        int = 100
 	maxServiceDescriptionLength int = 300
+	// maxServiceMinutes is the longest duration or buffer, a day.
+	maxServiceMinutes  uint = 24 * 60
+	maxServiceCapacity uint = 1000
+	// maxPolicyHours is the widest window of a fee rule, a year.
+	maxPolicyHours uint = 365 * 24
+	// maxServiceDepth is how many parents are visited looking for a policy,
+	// to stop on cycles.
+	maxServiceDepth int = 16
 
 	servicesTable string = "services"
 )
@@ -113,3 +121,30 @@ func (d *Database) DeleteService(id uint) error {
 	res := d.DB.Scopes(byServiceID(id)).Delete(&Service{})
 	return res.Error
 }
+
+// GetEffectivePolicy returns the policy of the service or, if it has none,
+// the one of its closest parent that has one. If none has a policy,
+// gorm.ErrRecordNotFound is returned.
+func (d *Database) GetEffectivePolicy(id uint) (*types.EffectivePolicy, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("invalid id")
+	}
+
+	for depth := 0; depth < maxServiceDepth; depth++ {
+		var service Service
+		if err := d.DB.Model(&Service{}).Scopes(byServiceID(id)).First(&service).Error; err != nil {
+			return nil, err
+		}
+
+		if converted := service.toAPI(); converted.Policy != nil {
+			return &types.EffectivePolicy{ServiceID: service.ID, Policy: converted.Policy}, nil
+		}
+
+		if service.ParentID == nil {
+			break
+		}
+		id = *service.ParentID
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
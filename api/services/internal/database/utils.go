@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 
 	"github.com/asimpleidea/appoint/api/services/pkg/types"
@@ -62,5 +63,60 @@ func checkServiceBeforePut(service *types.Service) (*Service, error) {
 	serviceToReturn.Price = price
 	serviceToReturn.PublicPrice = service.PublicPrice
 
+	// -- Check the duration and buffers
+	if service.Duration > maxServiceMinutes ||
+		service.BufferBefore > maxServiceMinutes || service.BufferAfter > maxServiceMinutes {
+		return nil, fmt.Errorf("service duration or buffers too long")
+	}
+	serviceToReturn.Duration = service.Duration
+	serviceToReturn.BufferBefore = service.BufferBefore
+	serviceToReturn.BufferAfter = service.BufferAfter
+
+	// -- Check the capacity
+	if service.Capacity > maxServiceCapacity {
+		return nil, fmt.Errorf("service capacity too large")
+	}
+	serviceToReturn.Capacity = service.Capacity
+	serviceToReturn.RequiresApproval = service.RequiresApproval
+
+	// -- Check the policy
+	if service.Policy != nil {
+		if err := checkPolicy(service.Policy); err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(service.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode policy: %w", err)
+		}
+
+		serviceToReturn.Policy = sql.NullString{String: string(data), Valid: true}
+	}
+
 	return serviceToReturn, nil
 }
+
+func checkPolicy(policy *types.Policy) error {
+	for name, rules := range map[string][]types.FeeRule{
+		"cancellation": policy.Cancellation,
+		"reschedule":   policy.Reschedule,
+	} {
+		windows := map[uint]bool{}
+		for _, rule := range rules {
+			if rule.WithinHours == 0 || rule.WithinHours > maxPolicyHours {
+				return fmt.Errorf("invalid %s rule window provided", name)
+			}
+
+			if rule.FeePercent < 0 || rule.FeePercent > 100 {
+				return fmt.Errorf("invalid %s rule fee provided", name)
+			}
+
+			if windows[rule.WithinHours] {
+				return fmt.Errorf("%s rules with the same window provided", name)
+			}
+			windows[rule.WithinHours] = true
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"net/url"
 	"os"
@@ -80,6 +81,11 @@ func main() {
 	ops = &database.Database{DB: db, Logger: log}
 	log.Debug().Msg("connected to the database")
 
+	if err := ops.Migrate(); err != nil {
+		log.Fatal().Err(err).Msg("could not migrate the database, exiting...")
+		return
+	}
+
 	// -----------------------------------------
 	// Start the REST API server
 	// -----------------------------------------
@@ -92,6 +98,37 @@ func main() {
 
 	services := app.Group("/services")
 
+	services.Get("/:id/policy", func(c *fiber.Ctx) error {
+		var id uint
+		{
+			serviceID, err := url.PathUnescape(c.Params("id"))
+			if err != nil || serviceID == "" {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid id provided"))
+			}
+
+			servID, err := strconv.Atoi(serviceID)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid id provided"))
+			}
+
+			id = uint(servID)
+		}
+
+		policy, err := ops.GetEffectivePolicy(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(policy)
+	})
+
 	services.Get("/:id", func(c *fiber.Ctx) error {
 		var id uint
 		{
@@ -136,11 +173,17 @@ func main() {
 		// This is to prevent having ID, CreatedAt etc. in the request as well.
 		// TODO: find an alternative way?
 		createdServ, err := ops.CreateService(&types.Service{
-			Name:        newService.Name,
-			ParentID:    newService.ParentID,
-			Description: newService.Description,
-			Price:       newService.Price,
-			PublicPrice: newService.PublicPrice,
+			Name:             newService.Name,
+			ParentID:         newService.ParentID,
+			Description:      newService.Description,
+			Price:            newService.Price,
+			PublicPrice:      newService.PublicPrice,
+			Duration:         newService.Duration,
+			BufferBefore:     newService.BufferBefore,
+			BufferAfter:      newService.BufferAfter,
+			Capacity:         newService.Capacity,
+			RequiresApproval: newService.RequiresApproval,
+			Policy:           newService.Policy,
 		})
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).
@@ -188,12 +231,18 @@ func main() {
 		}
 
 		if err := ops.UpdateService(&types.Service{
-			ID:          existingService.ID,
-			ParentID:    serviceToUpdate.ParentID,
-			Name:        serviceToUpdate.Name,
-			Description: serviceToUpdate.Description,
-			Price:       serviceToUpdate.Price,
-			PublicPrice: serviceToUpdate.PublicPrice,
+			ID:               existingService.ID,
+			ParentID:         serviceToUpdate.ParentID,
+			Name:             serviceToUpdate.Name,
+			Description:      serviceToUpdate.Description,
+			Price:            serviceToUpdate.Price,
+			PublicPrice:      serviceToUpdate.PublicPrice,
+			Duration:         serviceToUpdate.Duration,
+			BufferBefore:     serviceToUpdate.BufferBefore,
+			BufferAfter:      serviceToUpdate.BufferAfter,
+			Capacity:         serviceToUpdate.Capacity,
+			RequiresApproval: serviceToUpdate.RequiresApproval,
+			Policy:           serviceToUpdate.Policy,
 		}); err != nil {
 			return c.Status(fiber.StatusInternalServerError).
 				Send([]byte(err.Error()))
@@ -0,0 +1,30 @@
+package types
+
+// Policy tells what customers are charged when they cancel or reschedule a
+// booking of a service. Services without one inherit the policy of their
+// closest parent that has one.
+type Policy struct {
+	// Cancellation are the fees for cancelling a booking. For example,
+	// "free cancellation until 24h before, then 50% fee" is a single rule
+	// with WithinHours 24 and FeePercent 50.
+	Cancellation []FeeRule `json:"cancellation,omitempty" yaml:"cancellation,omitempty"`
+	// Reschedule are the fees for moving a booking to another time.
+	Reschedule []FeeRule `json:"reschedule,omitempty" yaml:"reschedule,omitempty"`
+	// MaxReschedules is how many times a booking can be moved, if set.
+	MaxReschedules *uint `json:"max_reschedules,omitempty" yaml:"maxReschedules,omitempty"`
+}
+
+// FeeRule charges a percentage of the price of the service when less than
+// WithinHours are left before the booking starts. When more rules apply,
+// the one with the smallest WithinHours wins.
+type FeeRule struct {
+	WithinHours uint    `json:"within_hours" yaml:"withinHours"`
+	FeePercent  float64 `json:"fee_percent" yaml:"feePercent"`
+}
+
+// EffectivePolicy is the policy in effect for a service, which is the one
+// of ServiceID: either the service itself or one of its parents.
+type EffectivePolicy struct {
+	ServiceID uint    `json:"service_id" yaml:"serviceId"`
+	Policy    *Policy `json:"policy" yaml:"policy"`
+}
@@ -14,4 +14,19 @@ type Service struct {
 	Description string     `json:"description" yaml:"description"`
 	Price       *float64   `json:"price,omitempty" yaml:"price,omitempty"`
 	PublicPrice bool       `json:"public_price" yaml:"publicPrice"`
+	// Duration is how long the service takes, in minutes.
+	Duration uint `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// BufferBefore and BufferAfter are the minutes to keep free before and
+	// after the service, e.g. to prepare and to clean up.
+	BufferBefore uint `json:"buffer_before,omitempty" yaml:"bufferBefore,omitempty"`
+	BufferAfter  uint `json:"buffer_after,omitempty" yaml:"bufferAfter,omitempty"`
+	// Capacity is how many people can attend the service at once. Services
+	// with a capacity of more than one are classes, booked by session.
+	Capacity uint `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+	// RequiresApproval tells whether new bookings of the service must be
+	// confirmed by the staff, rather than being confirmed right away.
+	RequiresApproval bool `json:"requires_approval,omitempty" yaml:"requiresApproval,omitempty"`
+	// Policy is the cancellation and rescheduling policy of the service
+	// itself, without the inherited one.
+	Policy *Policy `json:"policy,omitempty" yaml:"policy,omitempty"`
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/internal/database"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/holidays"
+	"github.com/rs/zerolog"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	coredb "github.com/asimpleidea/appoint/api/core/pkg/database"
+)
+
+const (
+	dateFormat string = "2006-01-02"
+)
+
+func main() {
+	dbOpts := &coredb.Options{}
+	var (
+		file         string
+		timetableIDs string
+		fromValue    string
+		toValue      string
+		preview      bool
+		actor        string
+	)
+
+	// -----------------------------------------
+	// CLI Flags
+	// -----------------------------------------
+
+	flag.StringVar(&file, "file", "", "the ICS file to import.")
+	flag.StringVar(&timetableIDs, "timetables", "",
+		"comma separated IDs of the timetables where to import the closures.")
+	flag.StringVar(&fromValue, "from", time.Now().Format(dateFormat),
+		"the first day to import, as YYYY-MM-DD.")
+	flag.StringVar(&toValue, "to", "",
+		"the last day to import, as YYYY-MM-DD. Defaults to a year after from.")
+	flag.BoolVar(&preview, "preview", false,
+		"whether to only show what would be imported.")
+	flag.StringVar(&actor, "actor", "import-ics",
+		"who is importing the closures, as recorded in the history.")
+
+	flag.StringVar(&dbOpts.Host, "database.host", "localhost",
+		"the main database where to connect to.")
+	flag.IntVar(&dbOpts.Port, "database.port", 5432,
+		"the port to use to connect to the database.")
+	flag.StringVar(&dbOpts.User, "database.user", "postgres",
+		"the user to use to authenticate to the database.")
+	flag.StringVar(&dbOpts.Password, "database.password", "",
+		"the password to use to authenticate to the database.")
+	flag.StringVar(&dbOpts.Name, "database.name", "appointments",
+		"the name of the database to use.")
+	flag.BoolVar(&dbOpts.SSLMode, "database.sslmode", true,
+		"whether to use SSL mode.")
+	flag.StringVar(&dbOpts.Timezone, "database.timezone", "Europe/Rome",
+		"the timezone to use for dates.")
+	flag.Parse()
+
+	log := zerolog.New(os.Stderr).With().Logger()
+
+	// -----------------------------------------
+	// Parse the arguments
+	// -----------------------------------------
+
+	ids := []uint{}
+	for _, value := range strings.Split(timetableIDs, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id <= 0 {
+			log.Fatal().Str("id", value).Msg("invalid timetable id provided, exiting...")
+		}
+
+		ids = append(ids, uint(id))
+	}
+
+	from, err := time.Parse(dateFormat, fromValue)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid from provided, exiting...")
+	}
+
+	to := from.AddDate(1, 0, -1)
+	if toValue != "" {
+		if to, err = time.Parse(dateFormat, toValue); err != nil {
+			log.Fatal().Err(err).Msg("invalid to provided, exiting...")
+		}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not open file, exiting...")
+	}
+
+	closures, err := holidays.FromICS(f, from, to)
+	f.Close()
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not read file, exiting...")
+	}
+
+	// -----------------------------------------
+	// Import the closures
+	// -----------------------------------------
+
+	db, err := gorm.Open(postgres.Open(coredb.GeneratePostgresDSN(*dbOpts)), &gorm.Config{})
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not establish connection to the database, exiting...")
+	}
+	ops := &database.Database{DB: db, Logger: log, Timezone: dbOpts.Timezone, Actor: actor}
+
+	imported, err := ops.ImportClosures(ids, closures, preview)
+	if err != nil {
+		log.Fatal().Err(err).Msg("could not import closures, exiting...")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIMETABLE\tDATE\tNAME\tACTION")
+	for _, closure := range imported {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", closure.TimeTableID, closure.Date, closure.Name, closure.Action)
+	}
+	w.Flush()
+
+	if preview {
+		fmt.Println("preview only: nothing was written.")
+	}
+}
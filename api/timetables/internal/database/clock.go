@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// Clock is a time of the day in the "15:04" format, stored in a Postgres
+// time column. The empty Clock is stored as NULL.
+type Clock string
+
+// Scan implements sql.Scanner.
+func (c *Clock) Scan(value interface{}) error {
+	var clock string
+	switch v := value.(type) {
+	case nil:
+		*c = ""
+		return nil
+	case time.Time:
+		*c = Clock(v.Format(timeFormat))
+		return nil
+	case []byte:
+		clock = string(v)
+	case string:
+		clock = v
+	default:
+		return fmt.Errorf("cannot scan %T into a clock", value)
+	}
+
+	parsed, err := time.Parse("15:04:05", clock)
+	if err != nil {
+		if parsed, err = time.Parse(timeFormat, clock); err != nil {
+			return fmt.Errorf("cannot scan %s into a clock: %w", clock, err)
+		}
+	}
+
+	*c = Clock(parsed.Format(timeFormat))
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (c Clock) Value() (driver.Value, error) {
+	if c == "" {
+		return nil, nil
+	}
+
+	return string(c), nil
+}
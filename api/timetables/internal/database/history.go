@@ -0,0 +1,236 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+	"gorm.io/gorm"
+)
+
+// GetRevisions returns the changes made to the timetable, the most recent
+// first. Revisions of deleted timetables are returned as well.
+func (d *Database) GetRevisions(timetableID uint) ([]types.TimetableRevision, error) {
+	if timetableID == 0 {
+		return nil, fmt.Errorf("invalid timetable provided")
+	}
+
+	revisions := []TimetableRevision{}
+	if err := d.DB.Order("id desc").Model(&TimetableRevision{}).
+		Scopes(byParentTimetableID(timetableID)).
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.TimetableRevision, len(revisions))
+	for i := 0; i < len(revisions); i++ {
+		converted[i] = *revisions[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// RollbackTimetable brings the timetable back to what it looked like right
+// after the provided revision, restoring it if it was deleted. The rollback
+// is itself recorded as a revision.
+func (d *Database) RollbackTimetable(timetableID, revisionID uint) (*types.Timetable, error) {
+	var revision TimetableRevision
+	if err := d.DB.Model(&TimetableRevision{}).
+		Scopes(byParentTimetableID(timetableID)).
+		Where("id = ?", revisionID).First(&revision).Error; err != nil {
+		return nil, err
+	}
+
+	if !revision.After.Valid {
+		return nil, fmt.Errorf("cannot roll back to the deletion of a timetable")
+	}
+
+	var target types.Timetable
+	if err := json.Unmarshal([]byte(revision.After.String), &target); err != nil {
+		return nil, fmt.Errorf("cannot read revision %d: %w", revisionID, err)
+	}
+
+	if err := d.inRevision(timetableID, types.RevisionRollback, func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&Timetable{}).Scopes(byTimetableID(timetableID)).
+			Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("cannot restore timetable: %w", err)
+		}
+
+		restored := timetableFromAPI(&target)
+		restored.ID = timetableID
+		if err := checkOwnerConflict(tx, restored); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Timetable{}).Scopes(byTimetableID(timetableID)).
+			Select("name", "valid_from", "valid_until", "timezone", "rotation_weeks",
+				"rotation_start", "owner_type", "owner_id", "priority").
+			Updates(restored).Error; err != nil {
+			return fmt.Errorf("cannot restore timetable: %w", err)
+		}
+
+		if err := tx.Scopes(byParentTimetableID(timetableID)).Delete(&TimetableDay{}).Error; err != nil {
+			return fmt.Errorf("cannot delete timetable days: %w", err)
+		}
+
+		if err := tx.Scopes(byParentTimetableID(timetableID)).Delete(&TimetableException{}).Error; err != nil {
+			return fmt.Errorf("cannot delete exceptions: %w", err)
+		}
+
+		days := []TimetableDay{}
+		for _, dow := range []types.DOW{types.Monday, types.Tuesday, types.Wednesday,
+			types.Thursday, types.Friday, types.Saturday, types.Sunday} {
+			for _, day := range target.WeekDay(dow) {
+				days = append(days, TimetableDay{
+					TimetableID: timetableID,
+					Dow:         DOW(dow),
+					Week:        day.Week,
+					Opening:     Clock(day.Opening),
+					Closing:     Clock(day.Closing),
+					Kind:        day.Kind,
+					Label:       day.Label,
+				})
+			}
+		}
+
+		if len(days) > 0 {
+			if err := tx.Create(days).Error; err != nil {
+				return fmt.Errorf("cannot create timetable days: %w", err)
+			}
+		}
+
+		exceptions := []TimetableException{}
+		for _, exception := range target.Exceptions {
+			date, err := time.Parse(dateFormat, exception.Date)
+			if err != nil {
+				return fmt.Errorf("invalid exception date %s: %w", exception.Date, err)
+			}
+
+			exceptions = append(exceptions, TimetableException{
+				TimetableID: timetableID,
+				Date:        date,
+				Name:        exception.Name,
+				Closed:      exception.Closed,
+				Opening:     Clock(exception.Opening),
+				Closing:     Clock(exception.Closing),
+				Source:      exception.Source,
+			})
+		}
+
+		if len(exceptions) > 0 {
+			if err := tx.Create(exceptions).Error; err != nil {
+				return fmt.Errorf("cannot create exceptions: %w", err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.GetTimetableByID(timetableID, true)
+}
+
+// inRevision applies the change to the timetable in a transaction, recording
+// what the timetable looked like before and after it.
+func (d *Database) inRevision(timetableID uint, action types.RevisionAction, change func(tx *gorm.DB) error) error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		before, err := d.snapshot(tx, timetableID)
+		if err != nil {
+			return err
+		}
+
+		if err := change(tx); err != nil {
+			return err
+		}
+
+		return d.saveRevision(tx, timetableID, action, before)
+	})
+}
+
+// saveRevision records a change to the timetable, taking the after snapshot
+// from the current state.
+func (d *Database) saveRevision(tx *gorm.DB, timetableID uint, action types.RevisionAction, before *types.Timetable) error {
+	after, err := d.snapshot(tx, timetableID)
+	if err != nil {
+		return err
+	}
+
+	toJSON := func(tt *types.Timetable) (sql.NullString, error) {
+		if tt == nil {
+			return sql.NullString{Valid: false}, nil
+		}
+
+		data, err := json.Marshal(tt)
+		if err != nil {
+			return sql.NullString{}, err
+		}
+
+		return sql.NullString{String: string(data), Valid: true}, nil
+	}
+
+	revision := TimetableRevision{
+		TimetableID: timetableID,
+		Action:      string(action),
+		Actor:       d.Actor,
+	}
+
+	if revision.Before, err = toJSON(before); err != nil {
+		return fmt.Errorf("cannot encode timetable: %w", err)
+	}
+
+	if revision.After, err = toJSON(after); err != nil {
+		return fmt.Errorf("cannot encode timetable: %w", err)
+	}
+
+	if err := tx.Create(&revision).Error; err != nil {
+		return fmt.Errorf("cannot create revision: %w", err)
+	}
+
+	return nil
+}
+
+// snapshot returns the timetable in full as seen by tx, or nil if it does
+// not exist.
+func (d *Database) snapshot(tx *gorm.DB, timetableID uint) (*types.Timetable, error) {
+	inTx := &Database{DB: tx, Logger: d.Logger, Timezone: d.Timezone}
+
+	tt, err := inTx.GetTimetableByID(timetableID, true)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("cannot get timetable %d: %w", timetableID, err)
+	}
+
+	return tt, nil
+}
+
+func timetableFromAPI(tt *types.Timetable) *Timetable {
+	timetable := &Timetable{
+		Name:          tt.Name,
+		ValidFrom:     tt.ValidFrom,
+		Timezone:      tt.Timezone,
+		RotationWeeks: tt.RotationWeeks,
+	}
+
+	if tt.ValidUntil != nil {
+		timetable.ValidUntil = sql.NullTime{Time: *tt.ValidUntil, Valid: true}
+	}
+
+	if tt.RotationStart != nil {
+		timetable.RotationStart = sql.NullTime{Time: *tt.RotationStart, Valid: true}
+	}
+
+	if tt.Owner != nil {
+		timetable.OwnerType = string(tt.Owner.Type)
+		timetable.OwnerID = tt.Owner.ID
+		timetable.Priority = tt.Owner.Priority
+	}
+
+	return timetable
+}
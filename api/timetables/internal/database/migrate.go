@@ -0,0 +1,110 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// clockPattern matches the opening and closing times that older
+	// versions stored as text and can be converted to time columns.
+	clockPattern string = "^([01]?[0-9]|2[0-3]):[0-5][0-9](:[0-5][0-9])?$"
+	// maxReportedRows is how many invalid rows are listed when a migration
+	// cannot go on because of them.
+	maxReportedRows int = 50
+)
+
+// Migrate creates the tables or brings them up to date. Opening and closing
+// times that older versions stored as text are converted to time columns.
+// If older versions accepted intervals that are invalid now, the migration
+// fails listing them, so that they can be fixed before the constraints are
+// added.
+func (d *Database) Migrate() error {
+	return d.DB.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{timetableDaysTable, timetableExceptionsTable} {
+			for _, column := range []string{"opening", "closing"} {
+				if err := migrateClockColumn(tx, table, column); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := checkInvalidIntervals(tx, timetableDaysTable,
+			"opening IS NULL OR closing IS NULL OR closing <= opening"); err != nil {
+			return err
+		}
+
+		if err := checkInvalidIntervals(tx, timetableExceptionsTable,
+			"NOT (closed OR COALESCE(closing > opening, false))"); err != nil {
+			return err
+		}
+
+		if err := tx.AutoMigrate(&Timetable{}, &TimetableDay{}, &TimetableException{}, &TimetableRevision{}); err != nil {
+			return fmt.Errorf("cannot migrate tables: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func migrateClockColumn(tx *gorm.DB, table, column string) error {
+	dataType := ""
+	if err := tx.Raw("SELECT data_type FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = ? AND column_name = ?",
+		table, column).Scan(&dataType).Error; err != nil {
+		return fmt.Errorf("cannot get type of %s.%s: %w", table, column, err)
+	}
+
+	if dataType != "text" && dataType != "character varying" {
+		// Either the table does not exist yet or it was already migrated.
+		return nil
+	}
+
+	// Soft-deleted rows are converted as well, so they are checked too.
+	if err := reportRows(tx, table, fmt.Sprintf("TRIM(COALESCE(%s, '')) <> '' AND TRIM(%s) !~ '%s'", column, column, clockPattern),
+		fmt.Sprintf("cannot convert %s.%s to time: these rows, soft-deleted ones included, have a value that is not a time", table, column)); err != nil {
+		return err
+	}
+
+	// Empty values, e.g. of closed exceptions, become NULL.
+	if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE time USING "+
+		"CASE WHEN TRIM(%s) ~ '%s' THEN TRIM(%s)::time END",
+		table, column, column, clockPattern, column)).Error; err != nil {
+		return fmt.Errorf("cannot convert %s.%s to time: %w", table, column, err)
+	}
+
+	return nil
+}
+
+// checkInvalidIntervals fails if rows of the table match invalid, listing
+// them, since the constraints cannot be added with them.
+func checkInvalidIntervals(tx *gorm.DB, table, invalid string) error {
+	if !tx.Migrator().HasTable(table) {
+		return nil
+	}
+
+	return reportRows(tx, table, invalid,
+		fmt.Sprintf("cannot add the constraints of %s: these rows, soft-deleted ones included, have an opening or closing time that is missing or not in order", table))
+}
+
+// reportRows fails with message if rows of the table match where, listing
+// their ids.
+func reportRows(tx *gorm.DB, table, where, message string) error {
+	ids := []uint{}
+	if err := tx.Raw(fmt.Sprintf("SELECT id FROM %s WHERE %s ORDER BY id LIMIT %d", table, where, maxReportedRows)).
+		Scan(&ids).Error; err != nil {
+		return fmt.Errorf("cannot check rows of %s: %w", table, err)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	listed := make([]string, len(ids))
+	for i, id := range ids {
+		listed[i] = fmt.Sprint(id)
+	}
+
+	return fmt.Errorf("%s and must be fixed or deleted first: %s", message, strings.Join(listed, ", "))
+}
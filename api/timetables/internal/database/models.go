@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
@@ -18,6 +19,16 @@ type Timetable struct {
 	Name       string
 	ValidFrom  time.Time
 	ValidUntil sql.NullTime
+	Timezone   string `gorm:"size:64"`
+	// RotationWeeks is the length of the rotation cycle, in weeks.
+	RotationWeeks int `gorm:"not null;default:1"`
+	// RotationStart is a day in the first week of the cycle. If not valid,
+	// ValidFrom is used.
+	RotationStart sql.NullTime
+	// OwnerType and OwnerID are empty for timetables without an owner.
+	OwnerType string `gorm:"size:20;index:idx_timetables_owner"`
+	OwnerID   uint   `gorm:"index:idx_timetables_owner"`
+	Priority  int    `gorm:"not null;default:0"`
 }
 
 func (t *Timetable) ToAPI() *types.Timetable {
@@ -45,7 +56,60 @@ func (t *Timetable) ToAPI() *types.Timetable {
 			until = t.ValidUntil.Time
 			return &until
 		}(),
+		Timezone:      t.Timezone,
+		RotationWeeks: t.cycleWeeks(),
+		RotationStart: func() *time.Time {
+			var start time.Time
+			if !t.RotationStart.Valid {
+				return nil
+			}
+
+			start = t.RotationStart.Time
+			return &start
+		}(),
+		Owner: func() *types.Owner {
+			if t.OwnerType == "" {
+				return nil
+			}
+
+			return &types.Owner{
+				Type:     types.OwnerType(t.OwnerType),
+				ID:       t.OwnerID,
+				Priority: t.Priority,
+			}
+		}(),
+	}
+}
+
+// cycleWeeks returns the length of the rotation cycle, which is one week
+// for timetables that do not rotate.
+func (t *Timetable) cycleWeeks() int {
+	if t.RotationWeeks < 1 {
+		return 1
+	}
+
+	return t.RotationWeeks
+}
+
+// isValidOn tells whether the day of date is inside the validity window.
+func (t *Timetable) isValidOn(date time.Time) bool {
+	date = dateOf(date)
+
+	if date.Before(dateOf(t.ValidFrom)) {
+		return false
+	}
+
+	return !t.ValidUntil.Valid || !date.After(dateOf(t.ValidUntil.Time))
+}
+
+// overlaps tells whether the validity windows of the timetables have at
+// least a day in common.
+func (t *Timetable) overlaps(other *Timetable) bool {
+	if t.ValidUntil.Valid && dateOf(t.ValidUntil.Time).Before(dateOf(other.ValidFrom)) {
+		return false
 	}
+
+	return !other.ValidUntil.Valid || !dateOf(other.ValidUntil.Time).Before(dateOf(t.ValidFrom))
 }
 
 func (t *Timetable) TableName() string {
@@ -68,8 +132,12 @@ type TimetableDay struct {
 	gorm.Model
 	TimetableID uint
 	Dow         DOW
-	Opening     string
-	Closing     string
+	// Week is the index of the week in the rotation cycle.
+	Week    int                `gorm:"not null;default:0"`
+	Opening Clock              `gorm:"type:time;not null"`
+	Closing Clock              `gorm:"type:time;not null;check:chk_timetable_days_closing,closing > opening"`
+	Kind    types.IntervalKind `gorm:"size:20;not null;default:open"`
+	Label   string             `gorm:"size:100"`
 }
 
 func (t *TimetableDay) ToAPI() *types.TimetableDay {
@@ -88,11 +156,99 @@ func (t *TimetableDay) ToAPI() *types.TimetableDay {
 		}(),
 		TimeTableID: t.TimetableID,
 		DayOfWeek:   types.DOW(t.Dow),
-		Opening:     t.Opening,
-		Closing:     t.Closing,
+		Week:        t.Week,
+		Opening:     string(t.Opening),
+		Closing:     string(t.Closing),
+		Kind: func() types.IntervalKind {
+			if t.Kind == "" {
+				return types.KindOpen
+			}
+
+			return t.Kind
+		}(),
+		Label: t.Label,
 	}
 }
 
 func (t *TimetableDay) TableName() string {
 	return "timetable_days"
 }
+
+type TimetableException struct {
+	gorm.Model
+	TimetableID uint
+	Date        time.Time `gorm:"type:date"`
+	Name        string    `gorm:"size:100"`
+	Closed      bool
+	Opening     Clock  `gorm:"type:time"`
+	Closing     Clock  `gorm:"type:time;check:chk_timetable_exceptions_closing,closed OR COALESCE(closing > opening, false)"`
+	Source      string `gorm:"size:255;index"`
+}
+
+func (t *TimetableException) ToAPI() *types.TimetableException {
+	return &types.TimetableException{
+		ID:        t.ID,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		DeletedAt: func() *time.Time {
+			var deleted time.Time
+			if !t.DeletedAt.Valid {
+				return nil
+			}
+
+			deleted = t.DeletedAt.Time
+			return &deleted
+		}(),
+		TimeTableID: t.TimetableID,
+		Date:        t.Date.Format(dateFormat),
+		Name:        t.Name,
+		Closed:      t.Closed,
+		Opening:     string(t.Opening),
+		Closing:     string(t.Closing),
+		Source:      t.Source,
+	}
+}
+
+func (t *TimetableException) TableName() string {
+	return timetableExceptionsTable
+}
+
+type TimetableRevision struct {
+	gorm.Model
+	TimetableID uint   `gorm:"index"`
+	Action      string `gorm:"size:50"`
+	Actor       string `gorm:"size:100"`
+	// Before and After are JSON snapshots of the whole timetable, NULL when
+	// it does not exist.
+	Before sql.NullString `gorm:"type:jsonb"`
+	After  sql.NullString `gorm:"type:jsonb"`
+}
+
+func (t *TimetableRevision) ToAPI() *types.TimetableRevision {
+	snapshot := func(data sql.NullString) *types.Timetable {
+		if !data.Valid {
+			return nil
+		}
+
+		var tt types.Timetable
+		if err := json.Unmarshal([]byte(data.String), &tt); err != nil {
+			return nil
+		}
+
+		return &tt
+	}
+
+	return &types.TimetableRevision{
+		ID:          t.ID,
+		CreatedAt:   t.CreatedAt,
+		TimeTableID: t.TimetableID,
+		Action:      types.RevisionAction(t.Action),
+		Actor:       t.Actor,
+		Before:      snapshot(t.Before),
+		After:       snapshot(t.After),
+	}
+}
+
+func (t *TimetableRevision) TableName() string {
+	return timetableRevisionsTable
+}
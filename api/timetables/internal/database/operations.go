@@ -1,31 +1,56 @@
 package database
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/asimpleidea/appoint/api/timetables/pkg/holidays"
 	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 )
 
-/*
-	TODOs:
-	- function to check if timetable exists
-*/
-
 const (
 	maxServiceNameLength        int = 100
 	maxServiceDescriptionLength int = 300
+	maxLabelLength              int = 100
+
+	timetablesTable          string = "timetables"
+	timetableDaysTable       string = "timetable_days"
+	timetableExceptionsTable string = "timetable_exceptions"
+	timetableRevisionsTable  string = "timetable_revisions"
+
+	timeFormat       string = "15:04"
+	dateFormat       string = "2006-01-02"
+	maxRotationWeeks int    = 52
+)
 
-	timetablesTable    string = "timetables"
-	timetableDaysTable string = "timetable_days"
+var (
+	// ErrOwnerConflict is returned when an owner would have two timetables
+	// with the same priority valid on the same day.
+	ErrOwnerConflict = errors.New("the owner already has a timetable with overlapping validity and the same priority")
+	// ErrInvalidWeek is returned when the days of a week cannot be set
+	// because the week or one of its days does not exist.
+	ErrInvalidWeek = errors.New("invalid week provided")
 )
 
 type Database struct {
 	DB     *gorm.DB
 	Logger zerolog.Logger
+	// Timezone is the IANA time zone used for timetables that do not
+	// specify one.
+	Timezone string
+	// Actor is who makes the changes, as recorded in the history.
+	Actor string
+}
+
+// WithActor returns a copy of the database that records changes as made by
+// the provided actor.
+func (d *Database) WithActor(actor string) *Database {
+	withActor := *d
+	withActor.Actor = actor
+	return &withActor
 }
 
 func (d *Database) GetTimetableByID(id uint, fullTimetable bool) (*types.Timetable, error) {
@@ -43,6 +68,9 @@ func (d *Database) GetTimetableByID(id uint, fullTimetable bool) (*types.Timetab
 	}
 
 	timetableToReturn := timetable.ToAPI()
+	if timetableToReturn.Timezone == "" {
+		timetableToReturn.Timezone = d.Timezone
+	}
 
 	if !fullTimetable {
 		return timetableToReturn, nil
@@ -69,6 +97,9 @@ func (d *Database) GetTimetableByID(id uint, fullTimetable bool) (*types.Timetab
 	if timetableToReturn.Sunday, err = d.GetWeekDay(id, Sunday); err != nil {
 		return nil, fmt.Errorf("cannot get sunday data")
 	}
+	if timetableToReturn.Exceptions, err = d.GetExceptions(id); err != nil {
+		return nil, fmt.Errorf("cannot get exceptions data")
+	}
 
 	return timetableToReturn, nil
 }
@@ -78,42 +109,123 @@ func (d *Database) CreateTimetable(tt *types.Timetable) (*types.Timetable, error
 		return nil, fmt.Errorf("nil timetable provided")
 	}
 
-	now, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	timetableToCreate, err := checkTimetableBeforeCreate(tt, d.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkOwnerConflict(tx, timetableToCreate); err != nil {
+			return err
+		}
 
-	// We make the comparisons in utc because we only care about the day here,
-	// not the time.
-	if tt.ValidFrom.UTC().Before(now.UTC()) {
-		return nil, fmt.Errorf("cannot start a timetable before the current day")
+		if err := tx.Create(timetableToCreate).Error; err != nil {
+			return err
+		}
+
+		return d.saveRevision(tx, timetableToCreate.ID, types.RevisionCreate, nil)
+	}); err != nil {
+		return nil, err
 	}
 
-	if tt.ValidUntil != nil {
-		if tt.ValidUntil.UTC().Before(tt.ValidFrom.UTC()) {
-			return nil, fmt.Errorf("invalid end validity provided")
+	return timetableToCreate.ToAPI(), nil
+}
+
+// CloneTimetable creates a new timetable with the name and validity of tt,
+// copying the days of the timetable with the provided ID and its exceptions
+// that fall inside the new validity. If
+// tt has no timezone, rotation or owner, the ones of the cloned timetable
+// are used.
+func (d *Database) CloneTimetable(id uint, tt *types.Timetable) (*types.Timetable, error) {
+	if tt == nil {
+		return nil, fmt.Errorf("nil timetable provided")
+	}
+
+	source, err := d.GetTimetableByID(id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := *tt
+	if clone.RotationWeeks == 0 {
+		// The days refer to the weeks of the cloned cycle.
+		clone.RotationWeeks = source.RotationWeeks
+		if clone.RotationStart == nil {
+			clone.RotationStart = source.RotationStart
 		}
 	}
 
-	timetableToCreate := &Timetable{
-		Name:      tt.Name,
-		ValidFrom: tt.ValidFrom,
-		ValidUntil: func() sql.NullTime {
-			if tt.ValidUntil != nil {
-				return sql.NullTime{
-					Time:  *tt.ValidUntil,
-					Valid: true,
-				}
-			}
+	if clone.Owner == nil {
+		clone.Owner = source.Owner
+	}
 
-			return sql.NullTime{Valid: false}
-		}(),
+	if clone.RotationWeeks != source.RotationWeeks {
+		return nil, fmt.Errorf("cannot change the rotation weeks of a cloned timetable")
 	}
 
-	if err := d.DB.Create(timetableToCreate).Error; err != nil {
+	timetableToCreate, err := checkTimetableBeforeCreate(&clone, source.Timezone)
+	if err != nil {
 		return nil, err
 	}
 
-	return timetableToCreate.ToAPI(), nil
+	if err := d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkOwnerConflict(tx, timetableToCreate); err != nil {
+			return err
+		}
+
+		if err := tx.Create(timetableToCreate).Error; err != nil {
+			return fmt.Errorf("cannot create timetable: %w", err)
+		}
+
+		days := []TimetableDay{}
+		if err := tx.Scopes(byParentTimetableID(id)).Find(&days).Error; err != nil {
+			return fmt.Errorf("cannot get timetable days: %w", err)
+		}
+
+		for i := range days {
+			days[i].Model = gorm.Model{}
+			days[i].TimetableID = timetableToCreate.ID
+		}
+
+		if len(days) > 0 {
+			if err := tx.Create(days).Error; err != nil {
+				return fmt.Errorf("cannot create timetable days: %w", err)
+			}
+		}
+
+		exceptions := []TimetableException{}
+		if err := tx.Scopes(byParentTimetableID(id)).Find(&exceptions).Error; err != nil {
+			return fmt.Errorf("cannot get exceptions: %w", err)
+		}
+
+		// Exceptions outside the validity of the clone would never apply.
+		inside := []TimetableException{}
+		for _, exception := range exceptions {
+			if !timetableToCreate.isValidOn(exception.Date) {
+				continue
+			}
+
+			exception.Model = gorm.Model{}
+			exception.TimetableID = timetableToCreate.ID
+			inside = append(inside, exception)
+		}
+
+		if len(inside) > 0 {
+			if err := tx.Create(inside).Error; err != nil {
+				return fmt.Errorf("cannot create exceptions: %w", err)
+			}
+		}
+
+		return d.saveRevision(tx, timetableToCreate.ID, types.RevisionClone, nil)
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.GetTimetableByID(timetableToCreate.ID, true)
 }
 
+// GetWeekDay returns the intervals of the day of the week, for all the weeks
+// of the rotation cycle.
 func (d *Database) GetWeekDay(timetableID uint, dow DOW) ([]types.TimetableDay, error) {
 	switch dow {
 	case Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday:
@@ -126,20 +238,12 @@ func (d *Database) GetWeekDay(timetableID uint, dow DOW) ([]types.TimetableDay,
 		return nil, fmt.Errorf("invalid timetable provided")
 	}
 
-	{
-		count := int64(0)
-		if err := d.DB.Model(&Timetable{}).
-			Scopes(byTimetableID(timetableID)).Count(&count).Error; err != nil {
-			return nil, fmt.Errorf("cannot check if timetable exists: %w", err)
-		}
-
-		if count == 0 {
-			return nil, gorm.ErrRecordNotFound
-		}
+	if err := d.timetableExists(timetableID); err != nil {
+		return nil, err
 	}
 
 	dows := []TimetableDay{}
-	if err := d.DB.Order("opening asc").Model(&TimetableDay{}).
+	if err := d.DB.Order("week asc, opening asc").Model(&TimetableDay{}).
 		Scopes(byParentTimetableID(timetableID), byDayOfWeek(dow)).
 		Find(&dows).Error; err != nil {
 		return nil, err
@@ -153,121 +257,478 @@ func (d *Database) GetWeekDay(timetableID uint, dow DOW) ([]types.TimetableDay,
 	return converted, nil
 }
 
-func (d *Database) CreateWeekDay(timetableID uint, dow DOW, openingClosing [][2]string) ([]types.TimetableDay, error) {
-	if len(openingClosing) == 0 {
+// CreateWeekDay replaces the intervals of the day of the week in the
+// provided week of the rotation cycle, which is 0 for timetables that do
+// not rotate.
+func (d *Database) CreateWeekDay(timetableID uint, dow DOW, week int, intervals []types.TimetableDay) ([]types.TimetableDay, error) {
+	if len(intervals) == 0 {
 		return nil, fmt.Errorf("no opening closing times provided")
 	}
 
-	{
-		count := int64(0)
-		if err := d.DB.Model(&Timetable{}).
-			Scopes(byTimetableID(timetableID)).Count(&count).Error; err != nil {
-			return nil, fmt.Errorf("cannot check if timetable exists: %w", err)
+	timetable, err := d.getTimetable(timetableID)
+	if err != nil {
+		return nil, err
+	}
+
+	if week < 0 || week >= timetable.cycleWeeks() {
+		return nil, fmt.Errorf("invalid week provided")
+	}
+
+	toCreate, err := checkDayIntervals(timetableID, dow, week, intervals)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.inRevision(timetableID, types.RevisionSetDay, func(tx *gorm.DB) error {
+		if err := tx.Scopes(byParentTimetableID(timetableID), byDayOfWeek(dow), byWeek(week)).Delete(&TimetableDay{}).Error; err != nil {
+			return fmt.Errorf("cannot delete existing timetable days: %w", err)
 		}
 
-		if count == 0 {
-			return nil, gorm.ErrRecordNotFound
+		if err := tx.Create(toCreate).Error; err != nil {
+			return fmt.Errorf("cannot create timetable days: %w", err)
 		}
+
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
-	times := [][2]time.Time{}
-	toCreate := []TimetableDay{}
-	const timeFormat = "15:04"
+	weekDays := make([]types.TimetableDay, len(toCreate))
+	for i := 0; i < len(toCreate); i++ {
+		weekDays[i] = *toCreate[i].ToAPI()
+	}
 
-	// First, check them...
-	for _, t := range openingClosing {
-		if len(t) == 0 {
+	return weekDays, nil
+}
+
+// SetWeek replaces all the days of the provided weeks of the rotation cycle
+// in one transaction. Days that are not in a week are left closed, weeks
+// that are not provided are left untouched.
+func (d *Database) SetWeek(timetableID uint, weeks map[int]map[DOW][]types.TimetableDay) (*types.Timetable, error) {
+	for _, week := range weeks {
+		for dow := range week {
+			switch dow {
+			case Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday:
+				// ok
+			default:
+				return nil, fmt.Errorf("%w: invalid day of week %q", ErrInvalidWeek, dow)
+			}
+		}
+	}
+
+	timetable, err := d.getTimetable(timetableID)
+	if err != nil {
+		return nil, err
+	}
+
+	toReplace := []int{}
+	toCreate := []TimetableDay{}
+	for week := 0; week < timetable.cycleWeeks(); week++ {
+		days, exists := weeks[week]
+		if !exists {
 			continue
 		}
+		toReplace = append(toReplace, week)
+
+		for _, dow := range []DOW{Monday, Tuesday, Wednesday, Thursday, Friday, Saturday, Sunday} {
+			rows, err := checkDayIntervals(timetableID, dow, week, days[dow])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s of week %d: %w", dow, week, err)
+			}
 
-		if t[0] == "" {
-			return nil, fmt.Errorf("invalid opening time provided")
+			toCreate = append(toCreate, rows...)
 		}
+	}
 
-		opening, err := time.Parse(timeFormat, t[0])
-		if err != nil {
-			return nil, fmt.Errorf("invalid opening time provided: %w", err)
+	if len(toReplace) != len(weeks) {
+		return nil, fmt.Errorf("%w: the timetable has %d weeks", ErrInvalidWeek, timetable.cycleWeeks())
+	}
+
+	if err := d.inRevision(timetableID, types.RevisionSetWeek, func(tx *gorm.DB) error {
+		if err := tx.Scopes(byParentTimetableID(timetableID)).Where("week IN ?", toReplace).Delete(&TimetableDay{}).Error; err != nil {
+			return fmt.Errorf("cannot delete existing timetable days: %w", err)
 		}
 
-		if t[1] == "" {
-			return nil, fmt.Errorf("invalid closing time provided")
+		if len(toCreate) == 0 {
+			return nil
 		}
 
-		closing, err := time.Parse(timeFormat, t[1])
-		if err != nil {
-			return nil, fmt.Errorf("invalid closing time provided: %w", err)
+		if err := tx.Create(toCreate).Error; err != nil {
+			return fmt.Errorf("cannot create timetable days: %w", err)
 		}
 
-		if closing.Before(opening) {
-			return nil, fmt.Errorf("invalid closing time provided")
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.GetTimetableByID(timetableID, true)
+}
+
+// DeleteWeekDay deletes the intervals of the day of the week, either in the
+// provided week of the rotation cycle or, if nil, in all of them.
+func (d *Database) DeleteWeekDay(timetableID uint, dow DOW, week *int) error {
+	if err := d.timetableExists(timetableID); err != nil {
+		return err
+	}
+
+	return d.inRevision(timetableID, types.RevisionDeleteDay, func(tx *gorm.DB) error {
+		query := tx.Scopes(byParentTimetableID(timetableID), byDayOfWeek(dow))
+		if week != nil {
+			query = query.Scopes(byWeek(*week))
 		}
 
-		for _, previous := range times {
-			if !opening.After(previous[0]) && !opening.After(previous[1]) {
-				return nil, fmt.Errorf("invalid opening time provided %s: %w", opening, err)
-			}
+		if err := query.Delete(&TimetableDay{}).Error; err != nil {
+			return fmt.Errorf("cannot delete timetable days: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (d *Database) DeleteTimetable(id uint) error {
+	if id == 0 {
+		return fmt.Errorf("invalid id provided")
+	}
+
+	if err := d.timetableExists(id); err != nil {
+		return err
+	}
+
+	return d.inRevision(id, types.RevisionDelete, func(tx *gorm.DB) error {
+		if err := tx.Delete(&Timetable{}, id).Error; err != nil {
+			return fmt.Errorf("could not delete timetable: %w", err)
+		}
+
+		if err := tx.Scopes(byParentTimetableID(id)).Delete(&TimetableDay{}).Error; err != nil {
+			return fmt.Errorf("cannot delete days for timetable: %w", err)
 		}
 
-		times = append(times, [2]time.Time{opening, closing})
-		toCreate = append(toCreate, TimetableDay{
+		if err := tx.Scopes(byParentTimetableID(id)).Delete(&TimetableException{}).Error; err != nil {
+			return fmt.Errorf("cannot delete exceptions for timetable: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (d *Database) GetExceptions(timetableID uint) ([]types.TimetableException, error) {
+	if timetableID == 0 {
+		return nil, fmt.Errorf("invalid timetable provided")
+	}
+
+	if err := d.timetableExists(timetableID); err != nil {
+		return nil, err
+	}
+
+	exceptions := []TimetableException{}
+	if err := d.DB.Order("date asc, opening asc").Model(&TimetableException{}).
+		Scopes(byParentTimetableID(timetableID)).
+		Find(&exceptions).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.TimetableException, len(exceptions))
+	for i := 0; i < len(exceptions); i++ {
+		converted[i] = *exceptions[i].ToAPI()
+	}
+
+	return converted, nil
+}
+
+// CreateException replaces the exceptions of the timetable on the provided
+// date. If no opening closing times are provided, the day is closed.
+func (d *Database) CreateException(timetableID uint, date time.Time, name string, openingClosing [][2]string) ([]types.TimetableException, error) {
+	if err := d.timetableExists(timetableID); err != nil {
+		return nil, err
+	}
+
+	times, err := parseOpeningClosing(openingClosing)
+	if err != nil {
+		return nil, err
+	}
+
+	date = dateOf(date)
+	toCreate := []TimetableException{}
+	for _, t := range times {
+		toCreate = append(toCreate, TimetableException{
 			TimetableID: timetableID,
-			Dow:         dow,
-			Opening:     opening.Format(timeFormat),
-			Closing:     closing.Format(timeFormat),
+			Date:        date,
+			Name:        name,
+			Opening:     Clock(t[0].Format(timeFormat)),
+			Closing:     Clock(t[1].Format(timeFormat)),
 		})
 	}
 
-	d.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Scopes(byParentTimetableID(timetableID), byDayOfWeek(dow)).Delete(&TimetableDay{}).Error; err != nil {
-			return fmt.Errorf("cannot delete existing timetable days: %w", err)
+	if len(toCreate) == 0 {
+		toCreate = append(toCreate, TimetableException{
+			TimetableID: timetableID,
+			Date:        date,
+			Name:        name,
+			Closed:      true,
+		})
+	}
+
+	if err := d.inRevision(timetableID, types.RevisionSetException, func(tx *gorm.DB) error {
+		if err := tx.Scopes(byParentTimetableID(timetableID), byDate(date)).Delete(&TimetableException{}).Error; err != nil {
+			return fmt.Errorf("cannot delete existing exceptions: %w", err)
 		}
 
 		if err := tx.Create(toCreate).Error; err != nil {
-			return fmt.Errorf("cannot create timetable days: %w", err)
+			return fmt.Errorf("cannot create exceptions: %w", err)
 		}
 
 		return nil
-	})
+	}); err != nil {
+		return nil, err
+	}
 
-	weekDays := make([]types.TimetableDay, len(toCreate))
+	exceptions := make([]types.TimetableException, len(toCreate))
 	for i := 0; i < len(toCreate); i++ {
-		weekDays[i] = *toCreate[i].ToAPI()
+		exceptions[i] = *toCreate[i].ToAPI()
 	}
 
-	return weekDays, nil
+	return exceptions, nil
 }
 
-func (d *Database) DeleteWeekDay(timetableID uint, dow DOW) error {
-	{
-		count := int64(0)
-		if err := d.DB.Model(&Timetable{}).
-			Scopes(byTimetableID(timetableID)).Count(&count).Error; err != nil {
-			return fmt.Errorf("cannot check if timetable exists: %w", err)
+func (d *Database) DeleteException(timetableID uint, date time.Time) error {
+	if err := d.timetableExists(timetableID); err != nil {
+		return err
+	}
+
+	return d.inRevision(timetableID, types.RevisionDeleteException, func(tx *gorm.DB) error {
+		if err := tx.Scopes(byParentTimetableID(timetableID), byDate(dateOf(date))).Delete(&TimetableException{}).Error; err != nil {
+			return fmt.Errorf("cannot delete exceptions: %w", err)
 		}
 
-		if count == 0 {
-			return gorm.ErrRecordNotFound
+		return nil
+	})
+}
+
+// ImportClosures creates a closure exception for each holiday on each of the
+// provided timetables, skipping the days outside of their validity. Days
+// that already have exceptions are left untouched. When preview is true,
+// nothing is written and only the outcome is returned.
+func (d *Database) ImportClosures(timetableIDs []uint, closures []holidays.Holiday, preview bool) ([]types.ImportedClosure, error) {
+	if len(timetableIDs) == 0 {
+		return nil, fmt.Errorf("no timetables provided")
+	}
+
+	imported := []types.ImportedClosure{}
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		for _, timetableID := range timetableIDs {
+			var timetable Timetable
+			if err := tx.Model(&Timetable{}).
+				Scopes(byTimetableID(timetableID)).First(&timetable).Error; err != nil {
+				return fmt.Errorf("cannot get timetable %d: %w", timetableID, err)
+			}
+
+			existing := []TimetableException{}
+			if err := tx.Scopes(byParentTimetableID(timetableID)).
+				Find(&existing).Error; err != nil {
+				return fmt.Errorf("cannot get exceptions: %w", err)
+			}
+
+			// The sources of the exceptions of each day.
+			sources := map[string][]string{}
+			for _, exception := range existing {
+				date := exception.Date.Format(dateFormat)
+				sources[date] = append(sources[date], exception.Source)
+			}
+
+			toCreate := []TimetableException{}
+			for _, closure := range closures {
+				if !timetable.isValidOn(closure.Date) {
+					continue
+				}
+
+				date := closure.Date.Format(dateFormat)
+				result := types.ImportedClosure{
+					TimeTableID: timetableID,
+					Date:        date,
+					Name:        closure.Name,
+					Source:      closure.Source,
+					Action:      types.ImportCreate,
+				}
+
+				for _, source := range sources[date] {
+					if source == closure.Source {
+						result.Action = types.ImportDuplicate
+						break
+					}
+
+					result.Action = types.ImportConflict
+				}
+
+				imported = append(imported, result)
+				if result.Action != types.ImportCreate {
+					continue
+				}
+
+				sources[date] = append(sources[date], closure.Source)
+				toCreate = append(toCreate, TimetableException{
+					TimetableID: timetableID,
+					Date:        dateOf(closure.Date),
+					Name:        closure.Name,
+					Closed:      true,
+					Source:      closure.Source,
+				})
+			}
+
+			if preview || len(toCreate) == 0 {
+				continue
+			}
+
+			before, err := d.snapshot(tx, timetableID)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Create(toCreate).Error; err != nil {
+				return fmt.Errorf("cannot create exceptions: %w", err)
+			}
+
+			if err := d.saveRevision(tx, timetableID, types.RevisionImportClosures, before); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imported, nil
+}
+
+// SetOwner changes the owner of the timetable, or removes it if owner is
+// nil.
+func (d *Database) SetOwner(timetableID uint, owner *types.Owner) (*types.Timetable, error) {
+	if owner != nil {
+		if err := checkOwner(owner); err != nil {
+			return nil, err
+		}
+	}
+
+	timetable, err := d.getTimetable(timetableID)
+	if err != nil {
+		return nil, err
+	}
+
+	timetable.OwnerType, timetable.OwnerID, timetable.Priority = "", 0, 0
+	if owner != nil {
+		timetable.OwnerType = string(owner.Type)
+		timetable.OwnerID = owner.ID
+		timetable.Priority = owner.Priority
+	}
+
+	if err := d.inRevision(timetableID, types.RevisionSetOwner, func(tx *gorm.DB) error {
+		if err := checkOwnerConflict(tx, timetable); err != nil {
+			return err
+		}
+
+		return tx.Model(timetable).Select("owner_type", "owner_id", "priority").
+			Updates(timetable).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return d.GetTimetableByID(timetableID, false)
+}
+
+// GetTimetablesByOwner returns the timetables of the owner, from the highest
+// priority to the lowest and then by start of validity.
+func (d *Database) GetTimetablesByOwner(ownerType types.OwnerType, ownerID uint) ([]types.Timetable, error) {
+	timetables := []Timetable{}
+	if err := d.DB.Order("priority desc, valid_from asc").Model(&Timetable{}).
+		Scopes(byOwner(ownerType, ownerID)).
+		Find(&timetables).Error; err != nil {
+		return nil, err
+	}
+
+	converted := make([]types.Timetable, len(timetables))
+	for i := 0; i < len(timetables); i++ {
+		converted[i] = *timetables[i].ToAPI()
+		if converted[i].Timezone == "" {
+			converted[i].Timezone = d.Timezone
 		}
 	}
 
-	if err := d.DB.Scopes(byParentTimetableID(timetableID), byDayOfWeek(dow)).Delete(&TimetableDay{}).Error; err != nil {
-		return fmt.Errorf("cannot delete timetable days: %w", err)
+	return converted, nil
+}
+
+// ResolveTimetable returns, in full, the timetable of the owner in effect on
+// the day of date: the one with the highest priority among the ones valid on
+// that day.
+func (d *Database) ResolveTimetable(ownerType types.OwnerType, ownerID uint, date time.Time) (*types.Timetable, error) {
+	timetables := []Timetable{}
+	if err := d.DB.Order("priority desc, id desc").Model(&Timetable{}).
+		Scopes(byOwner(ownerType, ownerID)).
+		Find(&timetables).Error; err != nil {
+		return nil, err
+	}
+
+	for _, timetable := range timetables {
+		if timetable.isValidOn(date) {
+			return d.GetTimetableByID(timetable.ID, true)
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+// checkOwnerConflict returns ErrOwnerConflict if the owner of the timetable
+// has another timetable with the same priority and overlapping validity.
+// The owner stays locked until tx ends, so that concurrent writes for the
+// same owner are checked one after the other: tx must write the timetable
+// before ending.
+func checkOwnerConflict(tx *gorm.DB, timetable *Timetable) error {
+	if timetable.OwnerType == "" {
+		return nil
+	}
+
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))",
+		fmt.Sprintf("timetables:owner:%s:%d", timetable.OwnerType, timetable.OwnerID)).Error; err != nil {
+		return fmt.Errorf("cannot lock the owner: %w", err)
+	}
+
+	others := []Timetable{}
+	if err := tx.Model(&Timetable{}).
+		Scopes(byOwner(types.OwnerType(timetable.OwnerType), timetable.OwnerID)).
+		Where("priority = ? AND id <> ?", timetable.Priority, timetable.ID).
+		Find(&others).Error; err != nil {
+		return fmt.Errorf("cannot get the timetables of the owner: %w", err)
+	}
+
+	for i := range others {
+		if timetable.overlaps(&others[i]) {
+			return fmt.Errorf("%w: timetable %d", ErrOwnerConflict, others[i].ID)
+		}
 	}
 
 	return nil
 }
 
-func (d *Database) DeleteTimetable(id uint) error {
-	if id == 0 {
-		return fmt.Errorf("invalid id provided")
+func (d *Database) getTimetable(timetableID uint) (*Timetable, error) {
+	var timetable Timetable
+	if err := d.DB.Model(&Timetable{}).
+		Scopes(byTimetableID(timetableID)).First(&timetable).Error; err != nil {
+		return nil, err
 	}
 
-	if err := d.DB.Delete(&Timetable{}, id).Error; err != nil {
-		return fmt.Errorf("could not delete timetable: %w", err)
+	return &timetable, nil
+}
+
+func (d *Database) timetableExists(timetableID uint) error {
+	count := int64(0)
+	if err := d.DB.Model(&Timetable{}).
+		Scopes(byTimetableID(timetableID)).Count(&count).Error; err != nil {
+		return fmt.Errorf("cannot check if timetable exists: %w", err)
 	}
 
-	if err := d.DB.Scopes(byParentTimetableID(id)).Delete(&Timetable{}).Error; err != nil {
-		return fmt.Errorf("cannot delete days for timetable: %w", err)
+	if count == 0 {
+		return gorm.ErrRecordNotFound
 	}
 
 	return nil
@@ -1,6 +1,11 @@
 package database
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+	"gorm.io/gorm"
+)
 
 func byTimetableID(id uint) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
@@ -22,3 +27,24 @@ func byDayOfWeek(dow DOW) func(db *gorm.DB) *gorm.DB {
 			Where("dow = ?", dow)
 	}
 }
+
+func byWeek(week int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("week = ?", week)
+	}
+}
+
+func byDate(date time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("date = ?", date.Format(dateFormat))
+	}
+}
+
+func byOwner(ownerType types.OwnerType, ownerID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("owner_type = ? AND owner_id = ?", ownerType, ownerID)
+	}
+}
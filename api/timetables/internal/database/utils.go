@@ -0,0 +1,276 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+// dateOf returns the calendar day of t, as written in its own location, at
+// midnight UTC so that days can be compared regardless of time zones.
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func checkTimetableBeforeCreate(tt *types.Timetable, defaultTimezone string) (*Timetable, error) {
+	timezone := tt.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone provided: %w", err)
+	}
+
+	// Validity is expressed in days, so we compare the dates only: "today"
+	// is the current day where the timetable is, not where the server is.
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if dateOf(tt.ValidFrom).Before(today) {
+		return nil, fmt.Errorf("cannot start a timetable before the current day")
+	}
+
+	if tt.ValidUntil != nil {
+		if dateOf(*tt.ValidUntil).Before(dateOf(tt.ValidFrom)) {
+			return nil, fmt.Errorf("invalid end validity provided")
+		}
+	}
+
+	rotationWeeks := tt.RotationWeeks
+	switch {
+	case rotationWeeks == 0:
+		rotationWeeks = 1
+	case rotationWeeks < 0 || rotationWeeks > maxRotationWeeks:
+		return nil, fmt.Errorf("invalid rotation weeks provided")
+	}
+
+	timetable := &Timetable{
+		Name:          tt.Name,
+		Timezone:      loc.String(),
+		RotationWeeks: rotationWeeks,
+		RotationStart: func() sql.NullTime {
+			if tt.RotationStart != nil {
+				return sql.NullTime{
+					Time:  *tt.RotationStart,
+					Valid: true,
+				}
+			}
+
+			return sql.NullTime{Valid: false}
+		}(),
+		ValidFrom: tt.ValidFrom,
+		ValidUntil: func() sql.NullTime {
+			if tt.ValidUntil != nil {
+				return sql.NullTime{
+					Time:  *tt.ValidUntil,
+					Valid: true,
+				}
+			}
+
+			return sql.NullTime{Valid: false}
+		}(),
+	}
+
+	if tt.Owner != nil {
+		if err := checkOwner(tt.Owner); err != nil {
+			return nil, err
+		}
+
+		timetable.OwnerType = string(tt.Owner.Type)
+		timetable.OwnerID = tt.Owner.ID
+		timetable.Priority = tt.Owner.Priority
+	}
+
+	return timetable, nil
+}
+
+func checkOwner(owner *types.Owner) error {
+	switch owner.Type {
+	case types.OwnerLocation, types.OwnerStaff, types.OwnerResource, types.OwnerService:
+		// ok
+	default:
+		return fmt.Errorf("invalid owner type provided")
+	}
+
+	if owner.ID == 0 {
+		return fmt.Errorf("invalid owner id provided")
+	}
+
+	return nil
+}
+
+// parseOpeningClosing parses the opening and closing times, which cannot
+// overlap. All the problems found are returned as interval.Violations.
+func parseOpeningClosing(openingClosing [][2]string) ([][2]time.Time, error) {
+	intervals, violations := parseIntervals(openingClosing)
+
+	indexes := []int{}
+	valid := []interval.Interval{}
+	for i, parsed := range intervals {
+		if parsed != nil {
+			indexes = append(indexes, i)
+			valid = append(valid, *parsed)
+		}
+	}
+	violations = append(violations, remapViolations(interval.Validate(valid, true), indexes)...)
+
+	if len(violations) > 0 {
+		return nil, sortViolations(violations)
+	}
+
+	times := make([][2]time.Time, len(intervals))
+	for i, parsed := range intervals {
+		times[i] = [2]time.Time{parsed.Start, parsed.End}
+	}
+
+	return times, nil
+}
+
+// parseIntervals parses the opening and closing times. Intervals that
+// cannot be parsed are nil and reported as malformed.
+func parseIntervals(openingClosing [][2]string) ([]*interval.Interval, interval.Violations) {
+	intervals := make([]*interval.Interval, len(openingClosing))
+	violations := interval.Violations{}
+
+	for i, t := range openingClosing {
+		opening, err := time.Parse(timeFormat, t[0])
+		if err != nil {
+			violations = append(violations, interval.Violation{
+				Kind: interval.Malformed, Index: i, Other: -1,
+				Detail: fmt.Sprintf("invalid opening time %q", t[0]),
+			})
+			continue
+		}
+
+		closing, err := time.Parse(timeFormat, t[1])
+		if err != nil {
+			violations = append(violations, interval.Violation{
+				Kind: interval.Malformed, Index: i, Other: -1,
+				Detail: fmt.Sprintf("invalid closing time %q", t[1]),
+			})
+			continue
+		}
+
+		intervals[i] = &interval.Interval{Start: opening, End: closing}
+	}
+
+	return intervals, violations
+}
+
+// remapViolations converts the indexes of violations found in a subset of
+// a list to the indexes of the list.
+func remapViolations(violations interval.Violations, indexes []int) interval.Violations {
+	for i := range violations {
+		violations[i].Index = indexes[violations[i].Index]
+		if violations[i].Other >= 0 {
+			violations[i].Other = indexes[violations[i].Other]
+		}
+	}
+
+	return violations
+}
+
+func sortViolations(violations interval.Violations) interval.Violations {
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Index < violations[j].Index
+	})
+
+	return violations
+}
+
+// checkDayIntervals validates the intervals of a day of the week and
+// returns them as rows. Intervals of the same kind cannot overlap and breaks
+// must be inside an open interval. All the problems found are returned as
+// interval.Violations.
+func checkDayIntervals(timetableID uint, dow DOW, week int, intervals []types.TimetableDay) ([]TimetableDay, error) {
+	openingClosing := make([][2]string, len(intervals))
+	for i, day := range intervals {
+		openingClosing[i] = [2]string{day.Opening, day.Closing}
+	}
+
+	parsed, violations := parseIntervals(openingClosing)
+
+	kinds := make([]types.IntervalKind, len(intervals))
+	byKind := map[types.IntervalKind][]int{}
+	for i, day := range intervals {
+		kinds[i] = day.Kind
+		if kinds[i] == "" {
+			kinds[i] = types.KindOpen
+		}
+
+		switch kinds[i] {
+		case types.KindOpen, types.KindBreak, types.KindReception, types.KindPhone:
+			// ok
+		default:
+			violations = append(violations, interval.Violation{
+				Kind: interval.Malformed, Index: i, Other: -1,
+				Detail: fmt.Sprintf("invalid kind %q", day.Kind),
+			})
+			continue
+		}
+
+		if utf8.RuneCountInString(strings.TrimSpace(day.Label)) > maxLabelLength {
+			violations = append(violations, interval.Violation{
+				Kind: interval.Malformed, Index: i, Other: -1,
+				Detail: fmt.Sprintf("label is longer than %d characters", maxLabelLength),
+			})
+		}
+
+		if parsed[i] != nil {
+			byKind[kinds[i]] = append(byKind[kinds[i]], i)
+		}
+	}
+
+	for _, indexes := range byKind {
+		subset := make([]interval.Interval, len(indexes))
+		for j, i := range indexes {
+			subset[j] = *parsed[i]
+		}
+
+		violations = append(violations, remapViolations(interval.Validate(subset, true), indexes)...)
+	}
+
+	for _, b := range byKind[types.KindBreak] {
+		inside := false
+		for _, o := range byKind[types.KindOpen] {
+			if parsed[o].Contains(*parsed[b]) {
+				inside = true
+				break
+			}
+		}
+
+		if !inside {
+			violations = append(violations, interval.Violation{
+				Kind: interval.Uncovered, Index: b, Other: -1,
+				Detail: "breaks must be inside an open interval",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return nil, sortViolations(violations)
+	}
+
+	rows := make([]TimetableDay, len(intervals))
+	for i, day := range intervals {
+		rows[i] = TimetableDay{
+			TimetableID: timetableID,
+			Dow:         dow,
+			Week:        week,
+			Opening:     Clock(parsed[i].Start.Format(timeFormat)),
+			Closing:     Clock(parsed[i].End.Format(timeFormat)),
+			Kind:        kinds[i],
+			Label:       strings.TrimSpace(day.Label),
+		}
+	}
+
+	return rows, nil
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"net/url"
 	"os"
 	"os/signal"
@@ -12,6 +14,10 @@ import (
 	"time"
 
 	"github.com/asimpleidea/appoint/api/timetables/internal/database"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/holidays"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/openinghours"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/schedule"
 	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog"
@@ -19,10 +25,20 @@ import (
 	"gorm.io/gorm"
 
 	coredb "github.com/asimpleidea/appoint/api/core/pkg/database"
+	"github.com/asimpleidea/appoint/api/core/pkg/text"
 )
 
 const (
 	fiberAppName string = "Timetables API server"
+
+	actorHeader    string = "X-Actor"
+	maxActorLength int    = 100
+
+	defaultCalendarDays int = 30
+	maxCalendarDays     int = 366
+
+	// maxImportDays is how many days of a calendar can be imported at once.
+	maxImportDays int = 366
 )
 
 var (
@@ -86,9 +102,19 @@ func main() {
 		log.Fatal().Err(err).Msg("could not establish connection to the database, exiting...")
 		return
 	}
-	ops = &database.Database{DB: db, Logger: log}
+	if _, err := time.LoadLocation(dbOpts.Timezone); err != nil {
+		log.Fatal().Err(err).Str("timezone", dbOpts.Timezone).
+			Msg("invalid timezone provided, exiting...")
+		return
+	}
+	ops = &database.Database{DB: db, Logger: log, Timezone: dbOpts.Timezone}
 	log.Debug().Msg("connected to the database")
 
+	if err := ops.Migrate(); err != nil {
+		log.Fatal().Err(err).Msg("could not migrate the database, exiting...")
+		return
+	}
+
 	// // -----------------------------------------
 	// // Start the REST API server
 	// // -----------------------------------------
@@ -101,6 +127,171 @@ func main() {
 
 	timetables := app.Group("/timetables")
 
+	timetables.Get("/holidays", func(c *fiber.Ctx) error {
+		return c.JSON(holidays.Calendars())
+	})
+
+	timetables.Get("/owners/:type/:id", func(c *fiber.Ctx) error {
+		ownerType, ownerID, err := getOwner(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		res, err := ops.GetTimetablesByOwner(ownerType, ownerID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	timetables.Get("/owners/:type/:id/resolve", func(c *fiber.Ctx) error {
+		ownerType, ownerID, err := getOwner(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		// Timetables of the owner may be in different time zones, so the
+		// default one tells what "today" is.
+		loc, _ := time.LoadLocation(dbOpts.Timezone)
+		date, _ := time.Parse(schedule.DateFormat, time.Now().In(loc).Format(schedule.DateFormat))
+		if c.Query("date") != "" {
+			if date, err = time.Parse(schedule.DateFormat, c.Query("date")); err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid date provided"))
+			}
+		}
+
+		tt, err := ops.ResolveTimetable(ownerType, ownerID, date)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(tt)
+	})
+
+	timetables.Post(":id/holidays/:country/:year", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		year, err := strconv.Atoi(c.Params("year"))
+		if err != nil || year < 1583 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid year provided"))
+		}
+
+		closures, err := holidays.Generate(c.Params("country"), year)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).
+				Send([]byte(err.Error()))
+		}
+
+		preview := strings.ToLower(c.Query("preview", "false")) == "true"
+
+		imported, err := ops.WithActor(getActor(c)).ImportClosures([]uint{id}, closures, preview)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		if preview {
+			return c.JSON(imported)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(imported)
+	})
+
+	timetables.Post("/import/ics", func(c *fiber.Ctx) error {
+		timetableIDs, err := parseIDs(c.Query("timetables"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		preview := strings.ToLower(c.Query("preview", "false")) == "true"
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no calendar provided"))
+		}
+
+		closures, err := holidays.FromICS(bytes.NewReader(c.Body()), from, to)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		imported, err := ops.WithActor(getActor(c)).ImportClosures(timetableIDs, closures, preview)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		if preview {
+			return c.JSON(imported)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(imported)
+	})
+
+	timetables.Get("/:id.ics", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		tt, err := ops.GetTimetableByID(id, true)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		calendar, err := schedule.ICalendar(tt)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		var buf bytes.Buffer
+		if err := calendar.Encode(&buf); err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+		return c.Send(buf.Bytes())
+	})
+
 	timetables.Get("/:id", func(c *fiber.Ctx) error {
 		var id uint
 		{
@@ -134,6 +325,10 @@ func main() {
 				Send([]byte(err.Error()))
 		}
 
+		if kind := c.Query("kind"); kind != "" {
+			tt.FilterKind(types.IntervalKind(kind))
+		}
+
 		return c.JSON(tt)
 	})
 
@@ -151,8 +346,13 @@ func main() {
 				Send([]byte("invalid timetable provided"))
 		}
 
-		createdTt, err := ops.CreateTimetable(newTimeTable)
+		createdTt, err := ops.WithActor(getActor(c)).CreateTimetable(newTimeTable)
 		if err != nil {
+			if errors.Is(err, database.ErrOwnerConflict) {
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			}
+
 			return c.Status(fiber.StatusInternalServerError).
 				Send([]byte(err.Error()))
 		}
@@ -178,7 +378,11 @@ func main() {
 			id = uint(tid)
 		}
 
-		if err := ops.DeleteTimetable(id); err != nil {
+		if err := ops.WithActor(getActor(c)).DeleteTimetable(id); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
 			return c.Status(fiber.StatusInternalServerError).
 				JSON(err)
 		}
@@ -186,6 +390,483 @@ func main() {
 		return c.SendStatus(fiber.StatusOK)
 	})
 
+	timetables.Post(":id/clone", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no timetable provided"))
+		}
+
+		var newTimeTable *types.Timetable
+		if err := json.Unmarshal(c.Body(), &newTimeTable); err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid timetable provided"))
+		}
+
+		clonedTt, err := ops.WithActor(getActor(c)).CloneTimetable(id, newTimeTable)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			if errors.Is(err, database.ErrOwnerConflict) {
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(clonedTt)
+	})
+
+	timetables.Put(":id/owner", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		// An empty body removes the owner.
+		var owner *types.Owner
+		if len(c.Body()) > 0 {
+			if err := json.Unmarshal(c.Body(), &owner); err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid owner provided"))
+			}
+		}
+
+		tt, err := ops.WithActor(getActor(c)).SetOwner(id, owner)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			if errors.Is(err, database.ErrOwnerConflict) {
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(tt)
+	})
+
+	timetables.Post(":id/week", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no week provided"))
+		}
+
+		var newWeek types.Timetable
+		if err := json.Unmarshal(c.Body(), &newWeek); err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid week provided"))
+		}
+
+		existingTt, err := ops.GetTimetableByID(id, false)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		// All the weeks of the rotation cycle are replaced, even the ones
+		// without days.
+		weeks := map[int]map[database.DOW][]types.TimetableDay{}
+		for week := 0; week < existingTt.RotationWeeks; week++ {
+			weeks[week] = map[database.DOW][]types.TimetableDay{}
+		}
+
+		for _, dow := range []types.DOW{types.Monday, types.Tuesday,
+			types.Wednesday, types.Thursday, types.Friday, types.Saturday,
+			types.Sunday} {
+			for _, day := range newWeek.WeekDay(dow) {
+				if weeks[day.Week] == nil {
+					return c.Status(fiber.StatusBadRequest).
+						Send([]byte("invalid week provided"))
+				}
+
+				weeks[day.Week][database.DOW(dow)] = append(weeks[day.Week][database.DOW(dow)], day)
+			}
+		}
+
+		tt, err := ops.WithActor(getActor(c)).SetWeek(id, weeks)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			var violations interval.Violations
+			if errors.As(err, &violations) || errors.Is(err, database.ErrInvalidWeek) {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(tt)
+	})
+
+	timetables.Get(":id/revisions", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		revisions, err := ops.GetRevisions(id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(revisions)
+	})
+
+	timetables.Post(":id/revisions/:revision/rollback", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		revisionID, err := strconv.ParseUint(c.Params("revision"), 10, 0)
+		if err != nil || revisionID == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid revision provided"))
+		}
+
+		tt, err := ops.WithActor(getActor(c)).RollbackTimetable(id, uint(revisionID))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			if errors.Is(err, database.ErrOwnerConflict) {
+				return c.Status(fiber.StatusConflict).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(tt)
+	})
+
+	timetables.Get(":id/status", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		at := time.Now()
+		if c.Query("at") != "" {
+			if at, err = time.Parse(time.RFC3339, c.Query("at")); err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid at provided"))
+			}
+		}
+
+		tt, err := ops.GetTimetableByID(id, true)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		status, err := schedule.StatusAt(tt, at)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(status)
+	})
+
+	timetables.Get(":id/calendar", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		tt, err := ops.GetTimetableByID(id, true)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		loc, err := schedule.Location(tt)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		// By default, the next 30 days where the timetable is.
+		from, _ := time.Parse(schedule.DateFormat, time.Now().In(loc).Format(schedule.DateFormat))
+		if c.Query("from") != "" {
+			if from, err = time.Parse(schedule.DateFormat, c.Query("from")); err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid from provided"))
+			}
+		}
+
+		to := from.AddDate(0, 0, defaultCalendarDays-1)
+		if c.Query("to") != "" {
+			if to, err = time.Parse(schedule.DateFormat, c.Query("to")); err != nil {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte("invalid to provided"))
+			}
+		}
+
+		if to.Before(from) {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("to cannot be before from"))
+		}
+
+		if to.After(from.AddDate(0, 0, maxCalendarDays-1)) {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(fmt.Sprintf("cannot get more than %d days", maxCalendarDays)))
+		}
+
+		calendar, err := schedule.Calendar(tt, from, to)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(calendar)
+	})
+
+	timetables.Get(":id/opening-hours", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		week, _, err := getWeek(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		tt, err := ops.GetTimetableByID(id, true)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		openingHours, err := openinghours.FromTimetable(tt, week)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(types.OpeningHours{
+			OpeningHours: openingHours.Format(),
+		})
+	})
+
+	timetables.Post(":id/opening-hours", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		week, _, err := getWeek(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no opening hours provided"))
+		}
+
+		var newOpeningHours types.OpeningHours
+		if err := json.Unmarshal(c.Body(), &newOpeningHours); err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid opening hours provided"))
+		}
+
+		parsed, err := openinghours.Parse(newOpeningHours.OpeningHours)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		setWeek := map[database.DOW][]types.TimetableDay{}
+		for dow, times := range parsed {
+			for _, t := range times {
+				setWeek[database.DOW(dow)] = append(setWeek[database.DOW(dow)],
+					types.TimetableDay{Opening: t[0], Closing: t[1], Kind: types.KindOpen})
+			}
+		}
+
+		tt, err := ops.WithActor(getActor(c)).SetWeek(id, map[int]map[database.DOW][]types.TimetableDay{week: setWeek})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			var violations interval.Violations
+			if errors.As(err, &violations) || errors.Is(err, database.ErrInvalidWeek) {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(tt)
+	})
+
+	timetables.Get(":id/exceptions", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		res, err := ops.GetExceptions(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.JSON(res)
+	})
+
+	timetables.Post(":id/exceptions/:date", func(c *fiber.Ctx) error {
+		c.Accepts(fiber.MIMEApplicationJSON)
+
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		date, err := time.Parse(schedule.DateFormat, c.Params("date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid date provided"))
+		}
+
+		if len(c.Body()) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("no exception provided"))
+		}
+
+		var newExceptions []types.TimetableException
+		if err := json.Unmarshal(c.Body(), &newExceptions); err != nil || len(newExceptions) == 0 {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid exception provided"))
+		}
+
+		// A closure wins over any interval provided along with it.
+		times := [][2]string{}
+		for i := 0; i < len(newExceptions); i++ {
+			if newExceptions[i].Closed {
+				times = [][2]string{}
+				break
+			}
+
+			times = append(times, [2]string{newExceptions[i].Opening, newExceptions[i].Closing})
+		}
+
+		created, err := ops.WithActor(getActor(c)).CreateException(id, date, newExceptions[0].Name, times)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			var violations interval.Violations
+			if errors.As(err, &violations) {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte(err.Error()))
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(created)
+	})
+
+	timetables.Delete(":id/exceptions/:date", func(c *fiber.Ctx) error {
+		id, err := getTimetableID(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		}
+
+		date, err := time.Parse(schedule.DateFormat, c.Params("date"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte("invalid date provided"))
+		}
+
+		if err := ops.WithActor(getActor(c)).DeleteException(id, date); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.SendStatus(fiber.StatusNotFound)
+			}
+
+			return c.Status(fiber.StatusInternalServerError).
+				Send([]byte(err.Error()))
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	})
+
 	timetables.Get(":id/:dow", func(c *fiber.Ctx) error {
 		var id uint
 		{
@@ -229,6 +910,10 @@ func main() {
 			return c.Status(fiber.StatusInternalServerError).JSON(err)
 		}
 
+		if kind := c.Query("kind"); kind != "" {
+			res = types.FilterKind(res, types.IntervalKind(kind))
+		}
+
 		return c.JSON(res)
 	})
 
@@ -273,13 +958,20 @@ func main() {
 				Send([]byte("invalid timetable provided"))
 		}
 
-		times := [][2]string{}
-		for i := 0; i < len(newDOW); i++ {
-			times = append(times, [2]string{newDOW[i].Opening, newDOW[i].Closing})
+		week, _, err := getWeek(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
 		}
 
-		createdDow, err := ops.CreateWeekDay(id, database.DOW(dow), times)
+		createdDow, err := ops.WithActor(getActor(c)).CreateWeekDay(id, database.DOW(dow), week, newDOW)
 		if err != nil {
+			var violations interval.Violations
+			if errors.As(err, &violations) {
+				return c.Status(fiber.StatusBadRequest).
+					Send([]byte(err.Error()))
+			}
+
 			return c.Status(fiber.StatusInternalServerError).
 				Send([]byte(err.Error()))
 		}
@@ -315,7 +1007,15 @@ func main() {
 			return c.SendStatus(fiber.StatusNotFound)
 		}
 
-		if err := ops.DeleteWeekDay(id, database.DOW(dow)); err != nil {
+		var week *int
+		if w, provided, err := getWeek(c); err != nil {
+			return c.Status(fiber.StatusBadRequest).
+				Send([]byte(err.Error()))
+		} else if provided {
+			week = &w
+		}
+
+		if err := ops.WithActor(getActor(c)).DeleteWeekDay(id, database.DOW(dow), week); err != nil {
 			return c.Status(fiber.StatusInternalServerError).
 				JSON(err)
 		}
@@ -343,3 +1043,103 @@ func main() {
 	}
 	log.Info().Msg("goodbye!")
 }
+
+func getTimetableID(c *fiber.Ctx) (uint, error) {
+	timetableID, err := url.PathUnescape(c.Params("id"))
+	if err != nil || timetableID == "" {
+		return 0, fmt.Errorf("invalid id provided")
+	}
+
+	tid, err := strconv.Atoi(timetableID)
+	if err != nil || tid <= 0 {
+		return 0, fmt.Errorf("invalid id provided")
+	}
+
+	return uint(tid), nil
+}
+
+// parseIDs parses a comma separated list of IDs.
+func parseIDs(list string) ([]uint, error) {
+	ids := []uint{}
+
+	for _, value := range strings.Split(list, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id provided: %s", value)
+		}
+
+		ids = append(ids, uint(id))
+	}
+
+	return ids, nil
+}
+
+// parseDateRange parses the from and to dates, both included. If from is
+// empty, it is today; if to is empty, it is a year after from. They can be
+// at most maxImportDays apart.
+func parseDateRange(fromValue, toValue string) (time.Time, time.Time, error) {
+	from, _ := time.Parse(schedule.DateFormat, time.Now().Format(schedule.DateFormat))
+	if fromValue != "" {
+		var err error
+		if from, err = time.Parse(schedule.DateFormat, fromValue); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from provided")
+		}
+	}
+
+	to := from.AddDate(1, 0, -1)
+	if toValue != "" {
+		var err error
+		if to, err = time.Parse(schedule.DateFormat, toValue); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to provided")
+		}
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to cannot be before from")
+	}
+
+	if to.After(from.AddDate(0, 0, maxImportDays-1)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("cannot import more than %d days", maxImportDays)
+	}
+
+	return from, to, nil
+}
+
+// getActor returns who is making the request, as told by the X-Actor header,
+// to be recorded in the history of timetables.
+func getActor(c *fiber.Ctx) string {
+	return text.Truncate(strings.TrimSpace(c.Get(actorHeader)), maxActorLength)
+}
+
+// getOwner returns the owner type and ID from the path parameters.
+func getOwner(c *fiber.Ctx) (types.OwnerType, uint, error) {
+	ownerType := types.OwnerType(strings.ToLower(c.Params("type")))
+	switch ownerType {
+	case types.OwnerLocation, types.OwnerStaff, types.OwnerResource, types.OwnerService:
+		// OK
+	default:
+		return "", 0, fmt.Errorf("invalid owner type provided")
+	}
+
+	ownerID, err := strconv.ParseUint(c.Params("id"), 10, 0)
+	if err != nil || ownerID == 0 {
+		return "", 0, fmt.Errorf("invalid owner id provided")
+	}
+
+	return ownerType, uint(ownerID), nil
+}
+
+// getWeek returns the week of the rotation cycle from the week query
+// parameter, which is 0 if not provided.
+func getWeek(c *fiber.Ctx) (int, bool, error) {
+	if c.Query("week") == "" {
+		return 0, false, nil
+	}
+
+	week, err := strconv.Atoi(c.Query("week"))
+	if err != nil || week < 0 {
+		return 0, false, fmt.Errorf("invalid week provided")
+	}
+
+	return week, true, nil
+}
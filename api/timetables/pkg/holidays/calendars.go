@@ -0,0 +1,96 @@
+package holidays
+
+import "time"
+
+// calendars contains the national holidays of some countries. Regional
+// holidays are not included.
+var calendars = map[string]Calendar{
+	"IT": {
+		Country: "IT",
+		Name:    "Italy",
+		Rules: []Rule{
+			{ID: "new-year", Name: "Capodanno", Kind: Fixed, Month: time.January, Day: 1},
+			{ID: "epiphany", Name: "Epifania", Kind: Fixed, Month: time.January, Day: 6},
+			{ID: "easter", Name: "Pasqua", Kind: Easter},
+			{ID: "easter-monday", Name: "Lunedì dell'Angelo", Kind: Easter, Offset: 1},
+			{ID: "liberation-day", Name: "Festa della Liberazione", Kind: Fixed, Month: time.April, Day: 25},
+			{ID: "labour-day", Name: "Festa del Lavoro", Kind: Fixed, Month: time.May, Day: 1},
+			{ID: "republic-day", Name: "Festa della Repubblica", Kind: Fixed, Month: time.June, Day: 2},
+			{ID: "assumption", Name: "Ferragosto", Kind: Fixed, Month: time.August, Day: 15},
+			{ID: "all-saints", Name: "Ognissanti", Kind: Fixed, Month: time.November, Day: 1},
+			{ID: "immaculate-conception", Name: "Immacolata Concezione", Kind: Fixed, Month: time.December, Day: 8},
+			{ID: "christmas", Name: "Natale", Kind: Fixed, Month: time.December, Day: 25},
+			{ID: "st-stephen", Name: "Santo Stefano", Kind: Fixed, Month: time.December, Day: 26},
+		},
+	},
+	"FR": {
+		Country: "FR",
+		Name:    "France",
+		Rules: []Rule{
+			{ID: "new-year", Name: "Jour de l'an", Kind: Fixed, Month: time.January, Day: 1},
+			{ID: "easter-monday", Name: "Lundi de Pâques", Kind: Easter, Offset: 1},
+			{ID: "labour-day", Name: "Fête du Travail", Kind: Fixed, Month: time.May, Day: 1},
+			{ID: "victory-day", Name: "Victoire 1945", Kind: Fixed, Month: time.May, Day: 8},
+			{ID: "ascension", Name: "Ascension", Kind: Easter, Offset: 39},
+			{ID: "whit-monday", Name: "Lundi de Pentecôte", Kind: Easter, Offset: 50},
+			{ID: "bastille-day", Name: "Fête nationale", Kind: Fixed, Month: time.July, Day: 14},
+			{ID: "assumption", Name: "Assomption", Kind: Fixed, Month: time.August, Day: 15},
+			{ID: "all-saints", Name: "Toussaint", Kind: Fixed, Month: time.November, Day: 1},
+			{ID: "armistice", Name: "Armistice 1918", Kind: Fixed, Month: time.November, Day: 11},
+			{ID: "christmas", Name: "Noël", Kind: Fixed, Month: time.December, Day: 25},
+		},
+	},
+	"DE": {
+		Country: "DE",
+		Name:    "Germany",
+		Rules: []Rule{
+			{ID: "new-year", Name: "Neujahr", Kind: Fixed, Month: time.January, Day: 1},
+			{ID: "good-friday", Name: "Karfreitag", Kind: Easter, Offset: -2},
+			{ID: "easter-monday", Name: "Ostermontag", Kind: Easter, Offset: 1},
+			{ID: "labour-day", Name: "Tag der Arbeit", Kind: Fixed, Month: time.May, Day: 1},
+			{ID: "ascension", Name: "Christi Himmelfahrt", Kind: Easter, Offset: 39},
+			{ID: "whit-monday", Name: "Pfingstmontag", Kind: Easter, Offset: 50},
+			{ID: "unity-day", Name: "Tag der Deutschen Einheit", Kind: Fixed, Month: time.October, Day: 3},
+			{ID: "christmas", Name: "Erster Weihnachtstag", Kind: Fixed, Month: time.December, Day: 25},
+			{ID: "boxing-day", Name: "Zweiter Weihnachtstag", Kind: Fixed, Month: time.December, Day: 26},
+		},
+	},
+	"ES": {
+		Country: "ES",
+		Name:    "Spain",
+		Rules: []Rule{
+			{ID: "new-year", Name: "Año Nuevo", Kind: Fixed, Month: time.January, Day: 1},
+			{ID: "epiphany", Name: "Epifanía del Señor", Kind: Fixed, Month: time.January, Day: 6},
+			{ID: "good-friday", Name: "Viernes Santo", Kind: Easter, Offset: -2},
+			{ID: "labour-day", Name: "Fiesta del Trabajo", Kind: Fixed, Month: time.May, Day: 1},
+			{ID: "assumption", Name: "Asunción de la Virgen", Kind: Fixed, Month: time.August, Day: 15},
+			{ID: "national-day", Name: "Fiesta Nacional de España", Kind: Fixed, Month: time.October, Day: 12},
+			{ID: "all-saints", Name: "Todos los Santos", Kind: Fixed, Month: time.November, Day: 1},
+			{ID: "constitution-day", Name: "Día de la Constitución", Kind: Fixed, Month: time.December, Day: 6},
+			{ID: "immaculate-conception", Name: "Inmaculada Concepción", Kind: Fixed, Month: time.December, Day: 8},
+			{ID: "christmas", Name: "Natividad del Señor", Kind: Fixed, Month: time.December, Day: 25},
+		},
+	},
+	"US": {
+		Country: "US",
+		Name:    "United States (federal)",
+		Rules: []Rule{
+			{ID: "new-year", Name: "New Year's Day", Kind: Fixed, Month: time.January, Day: 1, Observed: true},
+			{ID: "mlk-day", Name: "Martin Luther King Jr. Day", Kind: NthWeekday, Month: time.January, Weekday: weekday(time.Monday), N: 3},
+			{ID: "presidents-day", Name: "Washington's Birthday", Kind: NthWeekday, Month: time.February, Weekday: weekday(time.Monday), N: 3},
+			{ID: "memorial-day", Name: "Memorial Day", Kind: NthWeekday, Month: time.May, Weekday: weekday(time.Monday), N: -1},
+			{ID: "juneteenth", Name: "Juneteenth", Kind: Fixed, Month: time.June, Day: 19, Observed: true, FromYear: 2021},
+			{ID: "independence-day", Name: "Independence Day", Kind: Fixed, Month: time.July, Day: 4, Observed: true},
+			{ID: "labor-day", Name: "Labor Day", Kind: NthWeekday, Month: time.September, Weekday: weekday(time.Monday), N: 1},
+			{ID: "columbus-day", Name: "Columbus Day", Kind: NthWeekday, Month: time.October, Weekday: weekday(time.Monday), N: 2},
+			{ID: "veterans-day", Name: "Veterans Day", Kind: Fixed, Month: time.November, Day: 11, Observed: true},
+			{ID: "thanksgiving", Name: "Thanksgiving Day", Kind: NthWeekday, Month: time.November, Weekday: weekday(time.Thursday), N: 4},
+			{ID: "christmas", Name: "Christmas Day", Kind: Fixed, Month: time.December, Day: 25, Observed: true},
+		},
+	},
+}
+
+// weekday returns a pointer to the day, for NthWeekday rules.
+func weekday(day time.Weekday) *time.Weekday {
+	return &day
+}
@@ -0,0 +1,32 @@
+// Package holidays produces the days on which timetables should be closed,
+// e.g. public holidays, to be imported as closure exceptions.
+package holidays
+
+import (
+	"time"
+
+	"github.com/asimpleidea/appoint/api/core/pkg/text"
+)
+
+const (
+	maxNameLength   int = 100
+	maxSourceLength int = 255
+)
+
+// Holiday is a day on which timetables are closed.
+type Holiday struct {
+	// Date is the day of the holiday, at midnight UTC.
+	Date time.Time
+	Name string
+	// Source identifies where the holiday comes from, so that importing it
+	// again does not duplicate it.
+	Source string
+}
+
+func newHoliday(year int, month time.Month, day int, name, source string) Holiday {
+	return Holiday{
+		Date:   time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+		Name:   text.Truncate(name, maxNameLength),
+		Source: text.Truncate(source, maxSourceLength),
+	}
+}
@@ -0,0 +1,193 @@
+package holidays
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/core/pkg/ical"
+)
+
+const (
+	icsSourcePrefix string = "ics:"
+	// maxRecurrenceDays is how many days the recurring events of a calendar
+	// can span in total, from their start to the end of the import, so
+	// that expanding them takes a bounded time.
+	maxRecurrenceDays int = 1000000
+)
+
+// FromICS reads an iCalendar file and returns a holiday for each day, from
+// from to to included, that is covered by one of its events. Recurring
+// events are expanded and multi-day events produce one holiday per day.
+func FromICS(r io.Reader, from, to time.Time) ([]Holiday, error) {
+	calendar, err := ical.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read calendar: %w", err)
+	}
+
+	if calendar.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("not a calendar")
+	}
+
+	from = dateOf(from)
+	to = dateOf(to)
+
+	// Events with a RECURRENCE-ID replace an occurrence of the recurring
+	// event with the same UID.
+	overridden := map[string]map[time.Time]bool{}
+	for _, event := range calendar.Components {
+		if event.Name != "VEVENT" || event.Get("RECURRENCE-ID") == nil {
+			continue
+		}
+
+		recurrenceID, _, err := event.Get("RECURRENCE-ID").Time(time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RECURRENCE-ID: %w", err)
+		}
+
+		uid := valueOf(event, "UID")
+		if overridden[uid] == nil {
+			overridden[uid] = map[time.Time]bool{}
+		}
+		overridden[uid][dateOf(recurrenceID)] = true
+	}
+
+	holidays := []Holiday{}
+	budget := maxRecurrenceDays
+	for _, event := range calendar.Components {
+		if event.Name != "VEVENT" || valueOf(event, "STATUS") == "CANCELLED" {
+			continue
+		}
+
+		eventHolidays, err := fromEvent(&event, overridden, from, to, &budget)
+		if err != nil {
+			return nil, fmt.Errorf("invalid event %s: %w", valueOf(event, "UID"), err)
+		}
+
+		holidays = append(holidays, eventHolidays...)
+	}
+
+	sort.SliceStable(holidays, func(i, j int) bool {
+		return holidays[i].Date.Before(holidays[j].Date)
+	})
+
+	return holidays, nil
+}
+
+// fromEvent returns the holidays of the event from from to to. The days
+// its recurrence spans are taken from budget.
+func fromEvent(event *ical.Component, overridden map[string]map[time.Time]bool, from, to time.Time, budget *int) ([]Holiday, error) {
+	dtstart := event.Get("DTSTART")
+	if dtstart == nil {
+		return nil, fmt.Errorf("no DTSTART")
+	}
+
+	start, isDate, err := dtstart.Time(time.UTC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DTSTART: %w", err)
+	}
+
+	// How many days each occurrence covers: DTEND is excluded for dates,
+	// but not for date-times.
+	days := 1
+	if dtend := event.Get("DTEND"); dtend != nil {
+		end, _, err := dtend.Time(start.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid DTEND: %w", err)
+		}
+
+		days = int(dateOf(end).Sub(dateOf(start)).Hours() / 24)
+		if !isDate && !end.Equal(dateOf(end).In(end.Location())) {
+			days++
+		}
+
+		if days < 1 {
+			days = 1
+		}
+	}
+
+	uid := valueOf(*event, "UID")
+	occurrences := []time.Time{start}
+	if rrule := event.Get("RRULE"); rrule != nil {
+		recurrence, err := ical.ParseRecurrence(rrule.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RRULE: %w", err)
+		}
+
+		// The end is in the location of start, one day later than needed
+		// to be on the safe side with time zones.
+		end := time.Date(to.Year(), to.Month(), to.Day()+2, 0, 0, 0, 0, start.Location())
+		if span := int(end.Sub(start).Hours() / 24); span > 0 {
+			if span > *budget {
+				return nil, fmt.Errorf("the recurring events of the calendar span more than %d days", maxRecurrenceDays)
+			}
+
+			*budget -= span
+		}
+
+		occurrences = recurrence.Occurrences(start, end)
+	}
+
+	excluded := map[time.Time]bool{}
+	for _, exdate := range event.GetAll("EXDATE") {
+		dates, err := exdate.Times(start.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXDATE: %w", err)
+		}
+
+		for _, date := range dates {
+			excluded[dateOf(date)] = true
+		}
+	}
+
+	if event.Get("RECURRENCE-ID") == nil {
+		for date := range overridden[uid] {
+			excluded[date] = true
+		}
+	}
+
+	if uid == "" {
+		uid = fmt.Sprintf("%s-%s", start.Format(ical.DateFormat), valueOf(*event, "SUMMARY"))
+	}
+
+	name := ical.Unescape(valueOf(*event, "SUMMARY"))
+	holidays := []Holiday{}
+	for _, occurrence := range occurrences {
+		if excluded[dateOf(occurrence)] {
+			continue
+		}
+
+		// Only the days from from to to are visited, however long the
+		// occurrence is.
+		first := dateOf(occurrence)
+		for i := 0; i < days; i++ {
+			date := first.AddDate(0, 0, i)
+			if date.Before(from) {
+				i += int(from.Sub(date).Hours()/24) - 1
+				continue
+			}
+
+			if date.After(to) {
+				break
+			}
+
+			holidays = append(holidays, newHoliday(date.Year(), date.Month(), date.Day(), name, icsSourcePrefix+uid))
+		}
+	}
+
+	return holidays, nil
+}
+
+func valueOf(c ical.Component, name string) string {
+	if prop := c.Get(name); prop != nil {
+		return prop.Value
+	}
+
+	return ""
+}
+
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
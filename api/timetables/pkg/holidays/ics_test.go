@@ -0,0 +1,112 @@
+package holidays
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func calendar(events ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(events, "") + "END:VCALENDAR\r\n"
+}
+
+func event(uid, summary string, properties ...string) string {
+	return "BEGIN:VEVENT\r\nUID:" + uid + "\r\nSUMMARY:" + summary + "\r\n" +
+		strings.Join(properties, "\r\n") + "\r\nEND:VEVENT\r\n"
+}
+
+func holidayDates(holidays []Holiday) []string {
+	dates := []string{}
+	for _, holiday := range holidays {
+		dates = append(dates, holiday.Date.Format("2006-01-02")+" "+holiday.Name)
+	}
+
+	return dates
+}
+
+func TestFromICS(t *testing.T) {
+	from := time.Date(2024, time.December, 20, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		calendar string
+		want     []string
+	}{
+		{
+			name: "single and multi-day events",
+			calendar: calendar(
+				event("a", "Christmas", "DTSTART;VALUE=DATE:20241225"),
+				event("b", "Closed for inventory", "DTSTART;VALUE=DATE:20250102", "DTEND;VALUE=DATE:20250104"),
+			),
+			want: []string{
+				"2024-12-25 Christmas",
+				"2025-01-02 Closed for inventory",
+				"2025-01-03 Closed for inventory",
+			},
+		},
+		{
+			name: "yearly since long ago",
+			calendar: calendar(
+				event("a", "New Year", "DTSTART;VALUE=DATE:19000101", "RRULE:FREQ=YEARLY"),
+			),
+			want: []string{"2025-01-01 New Year"},
+		},
+		{
+			name: "long event clipped to the range",
+			calendar: calendar(
+				event("a", "Renovation", "DTSTART;VALUE=DATE:20000101", "DTEND;VALUE=DATE:30000101"),
+			),
+			want: func() []string {
+				dates := []string{}
+				for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+					dates = append(dates, day.Format("2006-01-02")+" Renovation")
+				}
+
+				return dates
+			}(),
+		},
+		{
+			name: "cancelled and excluded",
+			calendar: calendar(
+				event("a", "Cancelled", "DTSTART;VALUE=DATE:20241224", "STATUS:CANCELLED"),
+				event("b", "Sunday", "DTSTART;VALUE=DATE:20241222", "RRULE:FREQ=WEEKLY;COUNT=3", "EXDATE;VALUE=DATE:20241229"),
+			),
+			want: []string{"2024-12-22 Sunday", "2025-01-05 Sunday"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			holidays, err := FromICS(strings.NewReader(tc.calendar), from, to)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := holidayDates(holidays); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFromICSRecurrenceBudget(t *testing.T) {
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+	// Each event recurs daily for more than a century before the range.
+	events := []string{}
+	for i := 0; i < 100; i++ {
+		events = append(events, event(fmt.Sprint(i), "Daily", "DTSTART;VALUE=DATE:19000101", "RRULE:FREQ=DAILY"))
+	}
+
+	if _, err := FromICS(strings.NewReader(calendar(events[:1]...)), from, to); err != nil {
+		t.Fatalf("a single long recurrence was refused: %s", err)
+	}
+
+	if _, err := FromICS(strings.NewReader(calendar(events...)), from, to); err == nil {
+		t.Fatal("expected an error for recurrences that span too many days")
+	}
+}
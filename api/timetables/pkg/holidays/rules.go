@@ -0,0 +1,167 @@
+package holidays
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	rulesSourcePrefix string = "rules:"
+)
+
+type RuleKind string
+
+const (
+	// Fixed is a holiday on the same day every year, e.g. December 25th.
+	Fixed RuleKind = "fixed"
+	// Easter is a holiday a number of days after Easter Sunday, e.g. one
+	// for Easter Monday. The offset can be negative, e.g. for Good Friday.
+	Easter RuleKind = "easter"
+	// NthWeekday is a holiday on the nth weekday of a month, e.g. the fourth
+	// Thursday of November. A negative N counts from the end of the month.
+	NthWeekday RuleKind = "nth-weekday"
+)
+
+// Rule tells when a holiday happens in a year.
+type Rule struct {
+	ID     string     `json:"id" yaml:"id"`
+	Name   string     `json:"name" yaml:"name"`
+	Kind   RuleKind   `json:"kind" yaml:"kind"`
+	Month  time.Month `json:"month,omitempty" yaml:"month,omitempty"`
+	Day    int        `json:"day,omitempty" yaml:"day,omitempty"`
+	Offset int        `json:"offset,omitempty" yaml:"offset,omitempty"`
+	// Weekday is only set for NthWeekday rules: it is a pointer so that
+	// Sunday is not omitted.
+	Weekday *time.Weekday `json:"weekday,omitempty" yaml:"weekday,omitempty"`
+	N       int           `json:"n,omitempty" yaml:"n,omitempty"`
+	// Observed moves holidays falling on Saturday to the Friday before and
+	// the ones falling on Sunday to the Monday after.
+	Observed bool `json:"observed,omitempty" yaml:"observed,omitempty"`
+	// FromYear is the first year the holiday exists, if not zero.
+	FromYear int `json:"from_year,omitempty" yaml:"fromYear,omitempty"`
+}
+
+// Calendar is a set of holiday rules, usually the national holidays of a
+// country.
+type Calendar struct {
+	Country string `json:"country" yaml:"country"`
+	Name    string `json:"name" yaml:"name"`
+	Rules   []Rule `json:"rules" yaml:"rules"`
+}
+
+// Date returns the day of the holiday in the provided year, or false if it
+// does not happen in that year.
+func (r *Rule) Date(year int) (time.Time, bool) {
+	if r.FromYear > 0 && year < r.FromYear {
+		return time.Time{}, false
+	}
+
+	var date time.Time
+	switch r.Kind {
+	case Fixed:
+		date = time.Date(year, r.Month, r.Day, 0, 0, 0, 0, time.UTC)
+		if date.Day() != r.Day {
+			// e.g. February 29th on a non leap year.
+			return time.Time{}, false
+		}
+	case Easter:
+		date = EasterSunday(year).AddDate(0, 0, r.Offset)
+	case NthWeekday:
+		if r.Weekday == nil || r.N == 0 {
+			return time.Time{}, false
+		}
+
+		weekday := int(*r.Weekday)
+		first := time.Date(year, r.Month, 1, 0, 0, 0, 0, time.UTC)
+		if r.N > 0 {
+			date = first.AddDate(0, 0, (weekday-int(first.Weekday())+7)%7+7*(r.N-1))
+		} else {
+			last := first.AddDate(0, 1, -1)
+			date = last.AddDate(0, 0, -((int(last.Weekday())-weekday+7)%7)+7*(r.N+1))
+		}
+
+		if date.Month() != r.Month {
+			return time.Time{}, false
+		}
+	default:
+		return time.Time{}, false
+	}
+
+	if r.Observed {
+		switch date.Weekday() {
+		case time.Saturday:
+			date = date.AddDate(0, 0, -1)
+		case time.Sunday:
+			date = date.AddDate(0, 0, 1)
+		}
+	}
+
+	return date, true
+}
+
+// EasterSunday returns the day of Easter Sunday in the Gregorian calendar,
+// with the anonymous Gregorian algorithm.
+func EasterSunday(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Calendars returns the bundled calendars, sorted by country.
+func Calendars() []Calendar {
+	list := make([]Calendar, 0, len(calendars))
+	for _, calendar := range calendars {
+		list = append(list, calendar)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Country < list[j].Country
+	})
+
+	return list
+}
+
+// Generate returns the holidays of the bundled calendar of the country in
+// the provided year, sorted by date.
+func Generate(country string, year int) ([]Holiday, error) {
+	calendar, exists := calendars[strings.ToUpper(country)]
+	if !exists {
+		return nil, fmt.Errorf("no holiday calendar for country %s", country)
+	}
+
+	return calendar.Generate(year), nil
+}
+
+// Generate returns the holidays of the calendar in the provided year,
+// sorted by date.
+func (c *Calendar) Generate(year int) []Holiday {
+	generated := []Holiday{}
+
+	for _, rule := range c.Rules {
+		date, happens := rule.Date(year)
+		if !happens {
+			continue
+		}
+
+		generated = append(generated, newHoliday(date.Year(), date.Month(), date.Day(),
+			rule.Name, rulesSourcePrefix+c.Country+":"+rule.ID))
+	}
+
+	sort.SliceStable(generated, func(i, j int) bool {
+		return generated[i].Date.Before(generated[j].Date)
+	})
+
+	return generated
+}
@@ -0,0 +1,70 @@
+package holidays
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNthWeekdayDate(t *testing.T) {
+	cases := []struct {
+		name string
+		rule Rule
+		year int
+		want time.Time
+		ok   bool
+	}{
+		{
+			name: "fourth thursday",
+			rule: Rule{Kind: NthWeekday, Month: time.November, Weekday: weekday(time.Thursday), N: 4},
+			year: 2024,
+			want: time.Date(2024, time.November, 28, 0, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "last monday",
+			rule: Rule{Kind: NthWeekday, Month: time.May, Weekday: weekday(time.Monday), N: -1},
+			year: 2024,
+			want: time.Date(2024, time.May, 27, 0, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "second sunday",
+			rule: Rule{Kind: NthWeekday, Month: time.May, Weekday: weekday(time.Sunday), N: 2},
+			year: 2024,
+			want: time.Date(2024, time.May, 12, 0, 0, 0, 0, time.UTC),
+			ok:   true,
+		},
+		{
+			name: "fifth sunday that does not exist",
+			rule: Rule{Kind: NthWeekday, Month: time.February, Weekday: weekday(time.Sunday), N: 5},
+			year: 2024,
+		},
+		{
+			name: "no weekday",
+			rule: Rule{Kind: NthWeekday, Month: time.May, N: 2},
+			year: 2024,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := c.rule.Date(c.year)
+			if ok != c.ok || !got.Equal(c.want) {
+				t.Errorf("Date(%d) = %s, %t, want %s, %t", c.year, got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestRuleJSONKeepsSunday(t *testing.T) {
+	encoded, err := json.Marshal(Rule{ID: "mothers-day", Kind: NthWeekday, Month: time.May, Weekday: weekday(time.Sunday), N: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(encoded), `"weekday":0`) {
+		t.Errorf("the weekday is missing from %s", encoded)
+	}
+}
@@ -0,0 +1,204 @@
+// Package interval contains operations on spans of time: sorting, merging,
+// subtracting and validating them.
+//
+// Times of the day can be handled by placing them on the same date, e.g. by
+// parsing them with time.Parse("15:04", ...).
+package interval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Interval is a span of time, from Start included to End excluded.
+type Interval struct {
+	Start time.Time `json:"start" yaml:"start"`
+	End   time.Time `json:"end" yaml:"end"`
+}
+
+// IsEmpty tells whether the interval does not end after it starts.
+func (i Interval) IsEmpty() bool {
+	return !i.End.After(i.Start)
+}
+
+// Overlaps tells whether the intervals have some time in common. Empty
+// intervals never overlap.
+func (i Interval) Overlaps(other Interval) bool {
+	if i.IsEmpty() || other.IsEmpty() {
+		return false
+	}
+
+	return i.Start.Before(other.End) && other.Start.Before(i.End)
+}
+
+// Adjacent tells whether one of the intervals ends exactly when the other
+// starts.
+func (i Interval) Adjacent(other Interval) bool {
+	return i.End.Equal(other.Start) || other.End.Equal(i.Start)
+}
+
+// Contains tells whether other is entirely inside the interval.
+func (i Interval) Contains(other Interval) bool {
+	return !other.Start.Before(i.Start) && !other.End.After(i.End)
+}
+
+// Sort sorts the intervals by start and then by end.
+func Sort(intervals []Interval) {
+	sort.SliceStable(intervals, func(i, j int) bool {
+		if !intervals[i].Start.Equal(intervals[j].Start) {
+			return intervals[i].Start.Before(intervals[j].Start)
+		}
+
+		return intervals[i].End.Before(intervals[j].End)
+	})
+}
+
+// Normalize returns the intervals sorted, without the empty ones and with
+// the ones that overlap or are adjacent merged together. The provided slice
+// is not modified.
+func Normalize(intervals []Interval) []Interval {
+	sorted := make([]Interval, 0, len(intervals))
+	for _, interval := range intervals {
+		if !interval.IsEmpty() {
+			sorted = append(sorted, interval)
+		}
+	}
+	Sort(sorted)
+
+	merged := []Interval{}
+	for _, interval := range sorted {
+		if last := len(merged) - 1; last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+
+			continue
+		}
+
+		merged = append(merged, interval)
+	}
+
+	return merged
+}
+
+// Subtract returns the parts of the intervals that are not covered by any of
+// the ones to remove, normalized.
+func Subtract(intervals, toRemove []Interval) []Interval {
+	result := []Interval{}
+	removed := Normalize(toRemove)
+
+	for _, interval := range Normalize(intervals) {
+		for _, r := range removed {
+			if !r.Overlaps(interval) {
+				continue
+			}
+
+			if r.Start.After(interval.Start) {
+				result = append(result, Interval{Start: interval.Start, End: r.Start})
+			}
+
+			interval.Start = r.End
+			if interval.IsEmpty() {
+				break
+			}
+		}
+
+		if !interval.IsEmpty() {
+			result = append(result, interval)
+		}
+	}
+
+	return result
+}
+
+type ViolationKind string
+
+const (
+	// Malformed is an interval whose bounds could not be parsed.
+	Malformed ViolationKind = "malformed"
+	// Empty is an interval that does not end after it starts.
+	Empty ViolationKind = "empty"
+	// Overlap is an interval that overlaps another one.
+	Overlap ViolationKind = "overlap"
+	// Adjacent is an interval that starts when another one ends.
+	Adjacent ViolationKind = "adjacent"
+	// Uncovered is an interval that should be inside another one, e.g. a
+	// break inside an open interval, but is not.
+	Uncovered ViolationKind = "uncovered"
+)
+
+// Violation is a problem with the interval at Index of a list.
+type Violation struct {
+	Kind  ViolationKind `json:"kind" yaml:"kind"`
+	Index int           `json:"index" yaml:"index"`
+	// Other is the index of the other interval involved, or -1.
+	Other  int    `json:"other" yaml:"other"`
+	Detail string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+func (v Violation) Error() string {
+	var message string
+	switch v.Kind {
+	case Malformed:
+		message = fmt.Sprintf("interval %d is malformed", v.Index)
+	case Empty:
+		message = fmt.Sprintf("interval %d does not end after it starts", v.Index)
+	case Overlap:
+		message = fmt.Sprintf("interval %d overlaps interval %d", v.Index, v.Other)
+	case Adjacent:
+		message = fmt.Sprintf("interval %d is adjacent to interval %d", v.Index, v.Other)
+	case Uncovered:
+		message = fmt.Sprintf("interval %d is not inside another interval", v.Index)
+	default:
+		message = fmt.Sprintf("interval %d is invalid", v.Index)
+	}
+
+	if v.Detail != "" {
+		message += ": " + v.Detail
+	}
+
+	return message
+}
+
+// Violations is a list of violations, usable as an error.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	messages := make([]string, len(v))
+	for i, violation := range v {
+		messages[i] = violation.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Validate returns every empty interval and every pair of overlapping
+// intervals, plus adjacent ones unless allowAdjacent is true. Indexes refer
+// to the provided slice and, for pairs, Index is the later of the two.
+func Validate(intervals []Interval, allowAdjacent bool) Violations {
+	violations := Violations{}
+
+	for i, interval := range intervals {
+		if interval.IsEmpty() {
+			violations = append(violations, Violation{Kind: Empty, Index: i, Other: -1})
+			continue
+		}
+
+		for j := 0; j < i; j++ {
+			if intervals[j].IsEmpty() {
+				continue
+			}
+
+			switch {
+			case interval.Overlaps(intervals[j]):
+				violations = append(violations, Violation{Kind: Overlap, Index: i, Other: j})
+			case !allowAdjacent && interval.Adjacent(intervals[j]):
+				violations = append(violations, Violation{Kind: Adjacent, Index: i, Other: j})
+			}
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,203 @@
+package interval
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// at returns 15:04 on the same date, so that tests can be written with
+// times of the day.
+func at(clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+func span(start, end string) Interval {
+	return Interval{Start: at(start), End: at(end)}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name      string
+		intervals []Interval
+		want      []Interval
+	}{
+		{
+			name:      "nil",
+			intervals: nil,
+			want:      []Interval{},
+		},
+		{
+			name:      "only empty",
+			intervals: []Interval{span("09:00", "09:00"), span("12:00", "10:00")},
+			want:      []Interval{},
+		},
+		{
+			name:      "unsorted and disjoint",
+			intervals: []Interval{span("14:00", "18:00"), span("09:00", "12:00")},
+			want:      []Interval{span("09:00", "12:00"), span("14:00", "18:00")},
+		},
+		{
+			name:      "touching",
+			intervals: []Interval{span("09:00", "12:00"), span("12:00", "14:00")},
+			want:      []Interval{span("09:00", "14:00")},
+		},
+		{
+			name:      "overlapping",
+			intervals: []Interval{span("09:00", "12:00"), span("11:00", "14:00")},
+			want:      []Interval{span("09:00", "14:00")},
+		},
+		{
+			name:      "nested",
+			intervals: []Interval{span("09:00", "18:00"), span("10:00", "11:00")},
+			want:      []Interval{span("09:00", "18:00")},
+		},
+		{
+			name:      "empty ones dropped before merging",
+			intervals: []Interval{span("09:00", "10:00"), span("10:30", "10:30"), span("11:00", "12:00")},
+			want:      []Interval{span("09:00", "10:00"), span("11:00", "12:00")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Normalize(c.intervals); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Normalize() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeDoesNotModifyInput(t *testing.T) {
+	intervals := []Interval{span("11:00", "14:00"), span("09:00", "12:00")}
+	Normalize(intervals)
+
+	if !reflect.DeepEqual(intervals, []Interval{span("11:00", "14:00"), span("09:00", "12:00")}) {
+		t.Errorf("the input was modified: %v", intervals)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	cases := []struct {
+		name      string
+		intervals []Interval
+		toRemove  []Interval
+		want      []Interval
+	}{
+		{
+			name:      "nothing to remove",
+			intervals: []Interval{span("09:00", "18:00")},
+			want:      []Interval{span("09:00", "18:00")},
+		},
+		{
+			name:     "nothing to remove from",
+			toRemove: []Interval{span("09:00", "18:00")},
+			want:     []Interval{},
+		},
+		{
+			name:      "nested",
+			intervals: []Interval{span("09:00", "18:00")},
+			toRemove:  []Interval{span("12:00", "13:00")},
+			want:      []Interval{span("09:00", "12:00"), span("13:00", "18:00")},
+		},
+		{
+			name:      "touching is not removed",
+			intervals: []Interval{span("09:00", "12:00")},
+			toRemove:  []Interval{span("12:00", "13:00"), span("08:00", "09:00")},
+			want:      []Interval{span("09:00", "12:00")},
+		},
+		{
+			name:      "whole interval",
+			intervals: []Interval{span("09:00", "12:00"), span("14:00", "18:00")},
+			toRemove:  []Interval{span("08:00", "13:00")},
+			want:      []Interval{span("14:00", "18:00")},
+		},
+		{
+			name:      "edges",
+			intervals: []Interval{span("09:00", "18:00")},
+			toRemove:  []Interval{span("08:00", "10:00"), span("17:00", "19:00")},
+			want:      []Interval{span("10:00", "17:00")},
+		},
+		{
+			name:      "one removal over several intervals",
+			intervals: []Interval{span("09:00", "12:00"), span("14:00", "18:00")},
+			toRemove:  []Interval{span("11:00", "15:00")},
+			want:      []Interval{span("09:00", "11:00"), span("15:00", "18:00")},
+		},
+		{
+			name:      "empty removals are ignored",
+			intervals: []Interval{span("09:00", "12:00")},
+			toRemove:  []Interval{span("10:00", "10:00")},
+			want:      []Interval{span("09:00", "12:00")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Subtract(c.intervals, c.toRemove); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Subtract() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name          string
+		intervals     []Interval
+		allowAdjacent bool
+		want          Violations
+	}{
+		{
+			name:      "valid",
+			intervals: []Interval{span("09:00", "12:00"), span("14:00", "18:00")},
+			want:      Violations{},
+		},
+		{
+			name:      "empty",
+			intervals: []Interval{span("09:00", "09:00")},
+			want:      Violations{{Kind: Empty, Index: 0, Other: -1}},
+		},
+		{
+			name:      "overlap",
+			intervals: []Interval{span("09:00", "12:00"), span("11:00", "14:00")},
+			want:      Violations{{Kind: Overlap, Index: 1, Other: 0}},
+		},
+		{
+			name:      "adjacent",
+			intervals: []Interval{span("09:00", "12:00"), span("12:00", "14:00")},
+			want:      Violations{{Kind: Adjacent, Index: 1, Other: 0}},
+		},
+		{
+			name:          "adjacent allowed",
+			intervals:     []Interval{span("09:00", "12:00"), span("12:00", "14:00")},
+			allowAdjacent: true,
+			want:          Violations{},
+		},
+		{
+			name: "every violation",
+			intervals: []Interval{
+				span("09:00", "12:00"), span("10:00", "11:00"),
+				span("13:00", "13:00"), span("12:00", "15:00"),
+			},
+			want: Violations{
+				{Kind: Overlap, Index: 1, Other: 0},
+				{Kind: Empty, Index: 2, Other: -1},
+				{Kind: Adjacent, Index: 3, Other: 0},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Validate(c.intervals, c.allowAdjacent); !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Validate() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Package openinghours converts the week of a timetable from and to the
+// OpenStreetMap opening_hours syntax, e.g. "Mo-Fr 09:00-18:00; Sa 10:00-13:00".
+//
+// Only weekday selectors, time spans and the off/closed keywords are
+// supported: anything else, e.g. months, public holidays or 24:00, is
+// rejected.
+package openinghours
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/schedule"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const (
+	clockFormat string = "15:04"
+)
+
+var (
+	weekDays = [7]types.DOW{
+		types.Monday, types.Tuesday, types.Wednesday, types.Thursday,
+		types.Friday, types.Saturday, types.Sunday,
+	}
+	dayCodes = [7]string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}
+
+	spacesAroundSeparators = regexp.MustCompile(`\s*([-,])\s*`)
+)
+
+// Week contains the opening and closing times of each day of the week.
+// Days that are not in it are closed.
+type Week map[types.DOW][][2]string
+
+// Parse parses an opening_hours string. As in OpenStreetMap, a rule
+// overrides what previous rules said about its days.
+func Parse(openingHours string) (Week, error) {
+	week := Week{}
+
+	openingHours = strings.TrimSpace(openingHours)
+	if openingHours == "" {
+		return nil, fmt.Errorf("no opening hours provided")
+	}
+
+	for _, rule := range strings.Split(openingHours, ";") {
+		rule = spacesAroundSeparators.ReplaceAllString(strings.TrimSpace(rule), "$1")
+		if rule == "" {
+			continue
+		}
+
+		fields := strings.Fields(rule)
+
+		days := weekDays[:]
+		if first := fields[0]; first[0] < '0' || first[0] > '9' {
+			if parsed, err := parseDays(first); err == nil {
+				days = parsed
+				fields = fields[1:]
+			} else if !isClosedKeyword(first) {
+				return nil, fmt.Errorf("invalid rule %q: %w", rule, err)
+			}
+		}
+
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("invalid rule %q: unsupported syntax", rule)
+		}
+
+		times, err := parseTimes(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", rule, err)
+		}
+
+		for _, day := range days {
+			if len(times) == 0 {
+				delete(week, day)
+				continue
+			}
+
+			week[day] = times
+		}
+	}
+
+	return week, nil
+}
+
+// FromTimetable returns the provided week of the rotation cycle of a
+// timetable loaded in full. Breaks are left out of the open intervals.
+func FromTimetable(tt *types.Timetable, cycleWeek int) (Week, error) {
+	week := Week{}
+
+	for _, dow := range weekDays {
+		days := []types.TimetableDay{}
+		for _, day := range tt.WeekDay(dow) {
+			if day.Week == cycleWeek {
+				days = append(days, day)
+			}
+		}
+
+		times, err := schedule.OpenIntervals(days)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(times) > 0 {
+			week[dow] = times
+		}
+	}
+
+	return week, nil
+}
+
+// Format renders the week in the opening_hours syntax, grouping the days
+// that have the same times.
+func (w Week) Format() string {
+	rules := []string{}
+	done := [7]bool{}
+
+	for i, dow := range weekDays {
+		if done[i] || len(w[dow]) == 0 {
+			continue
+		}
+
+		times := formatTimes(w[dow])
+
+		sameTimes := []int{}
+		for j := i; j < len(weekDays); j++ {
+			if !done[j] && formatTimes(w[weekDays[j]]) == times {
+				sameTimes = append(sameTimes, j)
+				done[j] = true
+			}
+		}
+
+		rules = append(rules, formatDays(sameTimes)+" "+times)
+	}
+
+	if len(rules) == 0 {
+		return "off"
+	}
+
+	return strings.Join(rules, "; ")
+}
+
+func parseDays(selector string) ([]types.DOW, error) {
+	days := []types.DOW{}
+
+	for _, part := range strings.Split(selector, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("invalid weekday range %q", part)
+		}
+
+		from, err := dayIndex(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+
+		to := from
+		if len(bounds) == 2 {
+			if to, err = dayIndex(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+
+		// Ranges can wrap around the end of the week, e.g. Fr-Mo.
+		for i := from; ; i = (i + 1) % len(weekDays) {
+			days = append(days, weekDays[i])
+			if i == to {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
+
+func dayIndex(code string) (int, error) {
+	for i, dayCode := range dayCodes {
+		if code == dayCode {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unsupported weekday %q", code)
+}
+
+func isClosedKeyword(keyword string) bool {
+	return keyword == "off" || keyword == "closed"
+}
+
+func parseTimes(spans string) ([][2]string, error) {
+	if isClosedKeyword(spans) {
+		return [][2]string{}, nil
+	}
+
+	times := [][2]string{}
+	for _, span := range strings.Split(spans, ",") {
+		bounds := strings.Split(span, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid time span %q", span)
+		}
+
+		opening, err := time.Parse(clockFormat, bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid opening time %q", bounds[0])
+		}
+
+		closing, err := time.Parse(clockFormat, bounds[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid closing time %q", bounds[1])
+		}
+
+		if !closing.After(opening) {
+			return nil, fmt.Errorf("time span %q does not end after it starts", span)
+		}
+
+		times = append(times, [2]string{opening.Format(clockFormat), closing.Format(clockFormat)})
+	}
+
+	return times, nil
+}
+
+func formatTimes(times [][2]string) string {
+	spans := make([]string, len(times))
+	for i, t := range times {
+		spans[i] = t[0] + "-" + t[1]
+	}
+
+	return strings.Join(spans, ",")
+}
+
+// formatDays renders sorted day indexes, using ranges for three or more
+// consecutive days.
+func formatDays(days []int) string {
+	parts := []string{}
+
+	for i := 0; i < len(days); {
+		j := i
+		for j+1 < len(days) && days[j+1] == days[j]+1 {
+			j++
+		}
+
+		switch {
+		case j-i >= 2:
+			parts = append(parts, dayCodes[days[i]]+"-"+dayCodes[days[j]])
+		case j == i+1:
+			parts = append(parts, dayCodes[days[i]], dayCodes[days[j]])
+		default:
+			parts = append(parts, dayCodes[days[i]])
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,155 @@
+package openinghours
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name         string
+		openingHours string
+		want         Week
+	}{
+		{
+			name:         "range",
+			openingHours: "Mo-Fr 09:00-18:00",
+			want: Week{
+				types.Monday:    {{"09:00", "18:00"}},
+				types.Tuesday:   {{"09:00", "18:00"}},
+				types.Wednesday: {{"09:00", "18:00"}},
+				types.Thursday:  {{"09:00", "18:00"}},
+				types.Friday:    {{"09:00", "18:00"}},
+			},
+		},
+		{
+			name:         "no days means every day",
+			openingHours: "10:00-12:00",
+			want: Week{
+				types.Monday:    {{"10:00", "12:00"}},
+				types.Tuesday:   {{"10:00", "12:00"}},
+				types.Wednesday: {{"10:00", "12:00"}},
+				types.Thursday:  {{"10:00", "12:00"}},
+				types.Friday:    {{"10:00", "12:00"}},
+				types.Saturday:  {{"10:00", "12:00"}},
+				types.Sunday:    {{"10:00", "12:00"}},
+			},
+		},
+		{
+			name:         "later rules override",
+			openingHours: "Mo-We 09:00-12:00,14:00-18:00; We off",
+			want: Week{
+				types.Monday:  {{"09:00", "12:00"}, {"14:00", "18:00"}},
+				types.Tuesday: {{"09:00", "12:00"}, {"14:00", "18:00"}},
+			},
+		},
+		{
+			name:         "range wrapping around the week",
+			openingHours: "Sa-Mo 10:00-13:00",
+			want: Week{
+				types.Saturday: {{"10:00", "13:00"}},
+				types.Sunday:   {{"10:00", "13:00"}},
+				types.Monday:   {{"10:00", "13:00"}},
+			},
+		},
+		{
+			name:         "spaces around separators",
+			openingHours: "Mo , We 9:00 - 12:00",
+			want: Week{
+				types.Monday:    {{"09:00", "12:00"}},
+				types.Wednesday: {{"09:00", "12:00"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := Parse(c.openingHours)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned %v", c.openingHours, err)
+			}
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("Parse(%q) = %v, want %v", c.openingHours, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, openingHours := range []string{
+		"",
+		"Mo-Fr 18:00-09:00",
+		"Mo-Fr 09:00-24:00",
+		"Xx 09:00-12:00",
+		"Jan Mo 09:00-12:00",
+		"PH off",
+		"Mo 09:00",
+	} {
+		if week, err := Parse(openingHours); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", openingHours, week)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		name string
+		week Week
+		want string
+	}{
+		{
+			name: "closed",
+			week: Week{},
+			want: "off",
+		},
+		{
+			name: "two consecutive days are not a range",
+			week: Week{
+				types.Saturday: {{"10:00", "13:00"}},
+				types.Sunday:   {{"10:00", "13:00"}},
+			},
+			want: "Sa,Su 10:00-13:00",
+		},
+		{
+			name: "groups by times",
+			week: Week{
+				types.Monday:    {{"09:00", "12:00"}, {"14:00", "18:00"}},
+				types.Tuesday:   {{"09:00", "12:00"}, {"14:00", "18:00"}},
+				types.Wednesday: {{"09:00", "12:00"}, {"14:00", "18:00"}},
+				types.Thursday:  {{"09:00", "13:00"}},
+				types.Friday:    {{"09:00", "12:00"}, {"14:00", "18:00"}},
+			},
+			want: "Mo-We,Fr 09:00-12:00,14:00-18:00; Th 09:00-13:00",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.week.Format(); got != c.want {
+				t.Errorf("Format() = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, openingHours := range []string{
+		"off",
+		"Mo-Fr 09:00-18:00",
+		"Mo-We,Fr 09:00-12:00,14:00-18:00; Th 09:00-13:00; Sa 10:00-12:00",
+		"Mo,We 08:30-12:30; Tu,Th 14:00-19:00",
+		"Mo-Su 00:00-23:59",
+	} {
+		week, err := Parse(openingHours)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned %v", openingHours, err)
+		}
+
+		if got := week.Format(); got != openingHours {
+			t.Errorf("Format(Parse(%q)) = %q", openingHours, got)
+		}
+	}
+}
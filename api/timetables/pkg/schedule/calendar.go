@@ -0,0 +1,57 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+// CalendarDay contains the concrete intervals of a timetable on a date.
+type CalendarDay struct {
+	Date      string    `json:"date" yaml:"date"`
+	DayOfWeek types.DOW `json:"day_of_week" yaml:"dayOfWeek"`
+	// Valid is false if the date is outside the validity window of the
+	// timetable, in which case there are no intervals.
+	Valid bool `json:"valid" yaml:"valid"`
+	// Exception is true if the intervals come from an exception rather than
+	// from the week.
+	Exception bool       `json:"exception" yaml:"exception"`
+	Name      string     `json:"name,omitempty" yaml:"name,omitempty"`
+	Intervals []Interval `json:"intervals" yaml:"intervals"`
+}
+
+// Calendar returns the concrete intervals of the timetable on each day from
+// the day of from to the day of to, both included, in the timetable's time
+// zone. The timetable must have been loaded in full.
+func Calendar(tt *types.Timetable, from, to time.Time) ([]CalendarDay, error) {
+	loc, err := Location(tt)
+	if err != nil {
+		return nil, err
+	}
+
+	days := []CalendarDay{}
+	for day, last := dateOf(from), dateOf(to); !day.After(last); day = day.AddDate(0, 0, 1) {
+		intervals, err := dayIn(tt, loc, day.Year(), day.Month(), day.Day())
+		if err != nil {
+			return nil, err
+		}
+
+		calendarDay := CalendarDay{
+			Date:      day.Format(DateFormat),
+			DayOfWeek: types.DOWFromWeekday(day.Weekday()),
+			Valid:     IsValidOn(tt, day.Year(), day.Month(), day.Day()),
+			Intervals: intervals,
+		}
+
+		if calendarDay.Valid {
+			if exceptions := ExceptionsOn(tt, day); len(exceptions) > 0 {
+				calendarDay.Exception = true
+				calendarDay.Name = exceptions[0].Name
+			}
+		}
+
+		days = append(days, calendarDay)
+	}
+
+	return days, nil
+}
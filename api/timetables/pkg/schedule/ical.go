@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/core/pkg/ical"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const (
+	icalProductID string = "-//appoint//timetables//EN"
+)
+
+var weekDays = [7]types.DOW{
+	types.Monday, types.Tuesday, types.Wednesday, types.Thursday,
+	types.Friday, types.Saturday, types.Sunday,
+}
+
+// ICalendar renders the timetable as an RFC 5545 calendar. Each open
+// interval of the week, without its breaks, becomes a recurring event,
+// repeating every week or every rotation cycle, bounded by the validity
+// window of the timetable. Dates with exceptions are excluded from the
+// recurrences and, unless they are closures, get their own events.
+// The timetable must have been loaded in full.
+func ICalendar(tt *types.Timetable) (*ical.Component, error) {
+	loc, err := Location(tt)
+	if err != nil {
+		return nil, err
+	}
+
+	tzid := map[string]string{"TZID": loc.String()}
+
+	calendar := &ical.Component{Name: "VCALENDAR"}
+	calendar.Add("VERSION", "2.0", nil)
+	calendar.Add("PRODID", icalProductID, nil)
+	calendar.Add("CALSCALE", "GREGORIAN", nil)
+	calendar.Add("X-WR-CALNAME", ical.Escape(tt.Name), nil)
+	calendar.Add("X-WR-TIMEZONE", loc.String(), nil)
+	calendar.Components = append(calendar.Components, ical.Timezone(loc, tt.ValidFrom.Year()))
+
+	validFrom := dateOf(tt.ValidFrom)
+	var validUntil *time.Time
+	if tt.ValidUntil != nil {
+		until := dateOf(*tt.ValidUntil)
+		validUntil = &until
+	}
+
+	exceptionDates := []time.Time{}
+	{
+		seen := map[string]bool{}
+		for _, exception := range tt.Exceptions {
+			if seen[exception.Date] {
+				continue
+			}
+			seen[exception.Date] = true
+
+			date, err := time.Parse(DateFormat, exception.Date)
+			if err != nil {
+				return nil, fmt.Errorf("invalid exception date %s: %w", exception.Date, err)
+			}
+
+			exceptionDates = append(exceptionDates, date)
+		}
+	}
+
+	rotationWeeks := tt.RotationWeeks
+	if rotationWeeks < 1 {
+		rotationWeeks = 1
+	}
+
+	inCycleWeek := func(date time.Time, week int) bool {
+		return CycleWeek(tt, date.Year(), date.Month(), date.Day()) == week
+	}
+
+	for _, dow := range weekDays {
+		for week := 0; week < rotationWeeks; week++ {
+			days := []types.TimetableDay{}
+			var updatedAt time.Time
+			for _, day := range tt.WeekDay(dow) {
+				if day.Week != week {
+					continue
+				}
+
+				days = append(days, day)
+				if day.UpdatedAt.After(updatedAt) {
+					updatedAt = day.UpdatedAt
+				}
+			}
+
+			if len(days) == 0 {
+				continue
+			}
+
+			openingClosing, err := OpenIntervals(days)
+			if err != nil {
+				return nil, err
+			}
+
+			first := validFrom
+			for types.DOWFromWeekday(first.Weekday()) != dow || !inCycleWeek(first, week) {
+				first = first.AddDate(0, 0, 1)
+			}
+
+			if validUntil != nil && first.After(*validUntil) {
+				continue
+			}
+
+			for i, times := range openingClosing {
+				openingHour, openingMin, _ := ParseClock(times[0])
+				closingHour, closingMin, _ := ParseClock(times[1])
+
+				rule := "FREQ=WEEKLY;BYDAY=" + ical.WeekdayCode(first.Weekday())
+				if rotationWeeks > 1 {
+					rule = fmt.Sprintf("FREQ=WEEKLY;INTERVAL=%d;BYDAY=%s", rotationWeeks, ical.WeekdayCode(first.Weekday()))
+				}
+
+				if validUntil != nil {
+					last := *validUntil
+					for last.Weekday() != first.Weekday() || !inCycleWeek(last, week) {
+						last = last.AddDate(0, 0, -1)
+					}
+
+					until := WallClock(last.Year(), last.Month(), last.Day(), openingHour, openingMin, loc, false)
+					rule += ";UNTIL=" + until.UTC().Format(ical.UTCDateTimeFormat)
+				}
+
+				event := ical.Component{Name: "VEVENT"}
+				event.Add("UID", fmt.Sprintf("timetable-%d-%s-%d-%d@appoint", tt.ID, dow, week, i), nil)
+				event.Add("DTSTAMP", updatedAt.UTC().Format(ical.UTCDateTimeFormat), nil)
+				event.Add("SUMMARY", ical.Escape(tt.Name), nil)
+				event.Add("DTSTART", localDateTime(first, openingHour, openingMin), tzid)
+				event.Add("DTEND", localDateTime(first, closingHour, closingMin), tzid)
+				event.Add("RRULE", rule, nil)
+
+				for _, date := range exceptionDates {
+					if date.Weekday() == first.Weekday() && inCycleWeek(date, week) &&
+						!date.Before(first) && (validUntil == nil || !date.After(*validUntil)) {
+						event.Add("EXDATE", localDateTime(date, openingHour, openingMin), tzid)
+					}
+				}
+
+				calendar.Components = append(calendar.Components, event)
+			}
+		}
+	}
+
+	for _, exception := range tt.Exceptions {
+		if exception.Closed {
+			continue
+		}
+
+		date, _ := time.Parse(DateFormat, exception.Date)
+		if !IsValidOn(tt, date.Year(), date.Month(), date.Day()) {
+			continue
+		}
+
+		openingHour, openingMin, err := ParseClock(exception.Opening)
+		if err != nil {
+			return nil, fmt.Errorf("invalid opening time %s: %w", exception.Opening, err)
+		}
+
+		closingHour, closingMin, err := ParseClock(exception.Closing)
+		if err != nil {
+			return nil, fmt.Errorf("invalid closing time %s: %w", exception.Closing, err)
+		}
+
+		summary := tt.Name
+		if exception.Name != "" {
+			summary = exception.Name
+		}
+
+		event := ical.Component{Name: "VEVENT"}
+		event.Add("UID", fmt.Sprintf("timetable-%d-exception-%d@appoint", tt.ID, exception.ID), nil)
+		event.Add("DTSTAMP", exception.UpdatedAt.UTC().Format(ical.UTCDateTimeFormat), nil)
+		event.Add("SUMMARY", ical.Escape(summary), nil)
+		event.Add("DTSTART", localDateTime(date, openingHour, openingMin), tzid)
+		event.Add("DTEND", localDateTime(date, closingHour, closingMin), tzid)
+		calendar.Components = append(calendar.Components, event)
+	}
+
+	return calendar, nil
+}
+
+func dateOf(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func localDateTime(date time.Time, hour, min int) string {
+	return fmt.Sprintf("%sT%02d%02d00", date.Format(ical.DateFormat), hour, min)
+}
@@ -0,0 +1,296 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const (
+	// ClockFormat is the format of opening and closing times.
+	ClockFormat string = "15:04"
+	// DateFormat is the format of the dates of exceptions.
+	DateFormat string = "2006-01-02"
+)
+
+// Interval is a concrete span of time, from Start included to End excluded.
+type Interval = interval.Interval
+
+// Location returns the time zone of the timetable.
+func Location(tt *types.Timetable) (*time.Location, error) {
+	loc, err := time.LoadLocation(tt.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s: %w", tt.Timezone, err)
+	}
+
+	return loc, nil
+}
+
+// ParseClock parses a "15:04" time and returns its hour and minute.
+func ParseClock(clock string) (int, int, error) {
+	parsed, err := time.Parse(ClockFormat, clock)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return parsed.Hour(), parsed.Minute(), nil
+}
+
+// WallClock returns the instant at which the clocks in loc show the given
+// time on the given day.
+//
+// Daylight saving time transitions are handled as follows:
+//   - a time that is skipped, e.g. 02:30 when clocks jump from 02:00 to
+//     03:00, is moved forward by the length of the gap (03:30);
+//   - a time that happens twice, e.g. 02:30 when clocks go back from 03:00
+//     to 02:00, resolves to its first occurrence, unless late is true, in
+//     which case it resolves to the second one.
+func WallClock(year int, month time.Month, day, hour, min int, loc *time.Location, late bool) time.Time {
+	naive := time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+
+	// Time zones never change twice in a day, so the offsets in place a day
+	// before and a day after are the only candidates.
+	_, offsetBefore := naive.Add(-24 * time.Hour).In(loc).Zone()
+	_, offsetAfter := naive.Add(24 * time.Hour).In(loc).Zone()
+	first := naive.Add(-time.Duration(offsetBefore) * time.Second)
+	second := naive.Add(-time.Duration(offsetAfter) * time.Second)
+
+	shows := func(t time.Time) bool {
+		t = t.In(loc)
+		y, m, d := t.Date()
+		return y == year && m == month && d == day &&
+			t.Hour() == hour && t.Minute() == min
+	}
+
+	switch firstOK, secondOK := shows(first), shows(second); {
+	case firstOK && secondOK:
+		if late && second.After(first) {
+			return second.In(loc)
+		}
+
+		return first.In(loc)
+	case secondOK:
+		return second.In(loc)
+	default:
+		// Either the first is the right one or the time does not exist: in
+		// the latter case, using the offset before the transition moves it
+		// forward by the length of the gap.
+		return first.In(loc)
+	}
+}
+
+// IsValidOn tells whether the timetable is valid on the given day. The
+// validity window is made of days, both ends included, as written in
+// ValidFrom and ValidUntil.
+func IsValidOn(tt *types.Timetable, year int, month time.Month, day int) bool {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	fromYear, fromMonth, fromDay := tt.ValidFrom.Date()
+	if date.Before(time.Date(fromYear, fromMonth, fromDay, 0, 0, 0, 0, time.UTC)) {
+		return false
+	}
+
+	if tt.ValidUntil != nil {
+		untilYear, untilMonth, untilDay := tt.ValidUntil.Date()
+		if date.After(time.Date(untilYear, untilMonth, untilDay, 0, 0, 0, 0, time.UTC)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CycleWeek returns the index of the week of the rotation cycle that the
+// given day belongs to. Weeks start on Monday and the first one of the
+// cycle is the one containing RotationStart, or ValidFrom if nil.
+func CycleWeek(tt *types.Timetable, year int, month time.Month, day int) int {
+	if tt.RotationWeeks <= 1 {
+		return 0
+	}
+
+	anchor := tt.ValidFrom
+	if tt.RotationStart != nil {
+		anchor = *tt.RotationStart
+	}
+
+	anchorYear, anchorMonth, anchorDay := anchor.Date()
+	anchorDate := time.Date(anchorYear, anchorMonth, anchorDay, 0, 0, 0, 0, time.UTC)
+	// Go back to the Monday of that week.
+	anchorDate = anchorDate.AddDate(0, 0, -(int(anchorDate.Weekday())+6)%7)
+
+	days := int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Sub(anchorDate).Hours()) / 24
+	weeks := days / 7
+	if days < 0 && days%7 != 0 {
+		weeks--
+	}
+
+	week := weeks % tt.RotationWeeks
+	if week < 0 {
+		week += tt.RotationWeeks
+	}
+
+	return week
+}
+
+// Day returns the concrete intervals of the timetable on the given day, in
+// the timetable's time zone. The timetable must have been loaded in full.
+func Day(tt *types.Timetable, year int, month time.Month, day int) ([]Interval, error) {
+	loc, err := Location(tt)
+	if err != nil {
+		return nil, err
+	}
+
+	return dayIn(tt, loc, year, month, day)
+}
+
+// Expand returns the concrete intervals of the timetable between from and
+// to, cutting the ones that are only partially included.
+func Expand(tt *types.Timetable, from, to time.Time) ([]Interval, error) {
+	loc, err := Location(tt)
+	if err != nil {
+		return nil, err
+	}
+
+	intervals := []Interval{}
+	if !from.Before(to) {
+		return intervals, nil
+	}
+
+	first, last := from.In(loc), to.In(loc)
+	day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC)
+	lastDay := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+
+	for ; !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+		dayIntervals, err := dayIn(tt, loc, day.Year(), day.Month(), day.Day())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, interval := range dayIntervals {
+			if !interval.End.After(from) || !interval.Start.Before(to) {
+				continue
+			}
+
+			if interval.Start.Before(from) {
+				interval.Start = from.In(loc)
+			}
+
+			if interval.End.After(to) {
+				interval.End = to.In(loc)
+			}
+
+			intervals = append(intervals, interval)
+		}
+	}
+
+	return intervals, nil
+}
+
+// ExceptionsOn returns the exceptions of the timetable on the given day.
+func ExceptionsOn(tt *types.Timetable, date time.Time) []types.TimetableException {
+	day := date.Format(DateFormat)
+
+	exceptions := []types.TimetableException{}
+	for _, exception := range tt.Exceptions {
+		if exception.Date == day {
+			exceptions = append(exceptions, exception)
+		}
+	}
+
+	return exceptions
+}
+
+func dayIn(tt *types.Timetable, loc *time.Location, year int, month time.Month, day int) ([]Interval, error) {
+	if !IsValidOn(tt, year, month, day) {
+		return []Interval{}, nil
+	}
+
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	openingClosing := [][2]string{}
+	if exceptions := ExceptionsOn(tt, date); len(exceptions) > 0 {
+		for _, exception := range exceptions {
+			if exception.Closed {
+				return []Interval{}, nil
+			}
+
+			openingClosing = append(openingClosing, [2]string{exception.Opening, exception.Closing})
+		}
+	} else {
+		week := CycleWeek(tt, year, month, day)
+		days := []types.TimetableDay{}
+		for _, d := range tt.WeekDay(types.DOWFromWeekday(date.Weekday())) {
+			if d.Week == week {
+				days = append(days, d)
+			}
+		}
+
+		var err error
+		if openingClosing, err = OpenIntervals(days); err != nil {
+			return nil, err
+		}
+	}
+
+	intervals := make([]Interval, 0, len(openingClosing))
+	for _, d := range openingClosing {
+		openingHour, openingMin, err := ParseClock(d[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid opening time %s: %w", d[0], err)
+		}
+
+		closingHour, closingMin, err := ParseClock(d[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid closing time %s: %w", d[1], err)
+		}
+
+		interval := Interval{
+			Start: WallClock(year, month, day, openingHour, openingMin, loc, false),
+			End:   WallClock(year, month, day, closingHour, closingMin, loc, true),
+		}
+
+		// A skipped opening and closing may end up in the same instant.
+		if interval.End.After(interval.Start) {
+			intervals = append(intervals, interval)
+		}
+	}
+
+	interval.Sort(intervals)
+
+	return intervals, nil
+}
+
+// OpenIntervals returns the opening and closing times during which the
+// intervals of a day are open, that is the open intervals without their
+// breaks. Reception-only and phone-only intervals are ignored.
+func OpenIntervals(days []types.TimetableDay) ([][2]string, error) {
+	open, breaks := []Interval{}, []Interval{}
+	for _, d := range days {
+		opening, err := time.Parse(ClockFormat, d.Opening)
+		if err != nil {
+			return nil, fmt.Errorf("invalid opening time %s: %w", d.Opening, err)
+		}
+
+		closing, err := time.Parse(ClockFormat, d.Closing)
+		if err != nil {
+			return nil, fmt.Errorf("invalid closing time %s: %w", d.Closing, err)
+		}
+
+		switch d.Kind {
+		case types.KindOpen, "":
+			open = append(open, Interval{Start: opening, End: closing})
+		case types.KindBreak:
+			breaks = append(breaks, Interval{Start: opening, End: closing})
+		}
+	}
+
+	openingClosing := [][2]string{}
+	for _, i := range interval.Subtract(open, breaks) {
+		openingClosing = append(openingClosing, [2]string{
+			i.Start.Format(ClockFormat), i.End.Format(ClockFormat),
+		})
+	}
+
+	return openingClosing, nil
+}
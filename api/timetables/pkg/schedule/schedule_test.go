@@ -0,0 +1,204 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return loc
+}
+
+func TestWallClock(t *testing.T) {
+	rome := loadLocation(t, "Europe/Rome")
+
+	cases := []struct {
+		name   string
+		day    time.Time
+		hour   int
+		min    int
+		late   bool
+		want   time.Time
+		offset int
+	}{
+		{
+			name: "normal day",
+			day:  time.Date(2024, time.March, 30, 0, 0, 0, 0, time.UTC),
+			hour: 9, min: 30,
+			want:   time.Date(2024, time.March, 30, 8, 30, 0, 0, time.UTC),
+			offset: 3600,
+		},
+		{
+			name: "spring forward: skipped time is moved forward",
+			day:  time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC),
+			hour: 2, min: 30,
+			want:   time.Date(2024, time.March, 31, 1, 30, 0, 0, time.UTC),
+			offset: 7200,
+		},
+		{
+			name: "spring forward: time after the gap",
+			day:  time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC),
+			hour: 9, min: 0,
+			want:   time.Date(2024, time.March, 31, 7, 0, 0, 0, time.UTC),
+			offset: 7200,
+		},
+		{
+			name: "fall back: repeated time, first occurrence",
+			day:  time.Date(2024, time.October, 27, 0, 0, 0, 0, time.UTC),
+			hour: 2, min: 30,
+			want:   time.Date(2024, time.October, 27, 0, 30, 0, 0, time.UTC),
+			offset: 7200,
+		},
+		{
+			name: "fall back: repeated time, second occurrence",
+			day:  time.Date(2024, time.October, 27, 0, 0, 0, 0, time.UTC),
+			hour: 2, min: 30, late: true,
+			want:   time.Date(2024, time.October, 27, 1, 30, 0, 0, time.UTC),
+			offset: 3600,
+		},
+		{
+			name: "fall back: late has no effect outside the overlap",
+			day:  time.Date(2024, time.October, 27, 0, 0, 0, 0, time.UTC),
+			hour: 9, min: 0, late: true,
+			want:   time.Date(2024, time.October, 27, 8, 0, 0, 0, time.UTC),
+			offset: 3600,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := WallClock(c.day.Year(), c.day.Month(), c.day.Day(), c.hour, c.min, rome, c.late)
+			if !got.Equal(c.want) {
+				t.Errorf("WallClock() = %s, want %s", got.UTC(), c.want)
+			}
+
+			if _, offset := got.Zone(); offset != c.offset {
+				t.Errorf("WallClock() has offset %d, want %d", offset, c.offset)
+			}
+		})
+	}
+}
+
+func TestExpandAcrossDST(t *testing.T) {
+	rome := loadLocation(t, "Europe/Rome")
+
+	tt := &types.Timetable{
+		ValidFrom:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		Timezone:      "Europe/Rome",
+		RotationWeeks: 1,
+		Saturday: []types.TimetableDay{
+			{DayOfWeek: types.Saturday, Opening: "09:00", Closing: "12:00", Kind: types.KindOpen},
+		},
+		Sunday: []types.TimetableDay{
+			{DayOfWeek: types.Sunday, Opening: "01:00", Closing: "04:00", Kind: types.KindOpen},
+			{DayOfWeek: types.Sunday, Opening: "09:00", Closing: "12:00", Kind: types.KindOpen},
+		},
+	}
+
+	cases := []struct {
+		name      string
+		from      time.Time
+		to        time.Time
+		durations []time.Duration
+	}{
+		{
+			name:      "spring forward",
+			from:      time.Date(2024, time.March, 30, 0, 0, 0, 0, rome),
+			to:        time.Date(2024, time.April, 1, 0, 0, 0, 0, rome),
+			durations: []time.Duration{3 * time.Hour, 2 * time.Hour, 3 * time.Hour},
+		},
+		{
+			name:      "fall back",
+			from:      time.Date(2024, time.October, 26, 0, 0, 0, 0, rome),
+			to:        time.Date(2024, time.October, 28, 0, 0, 0, 0, rome),
+			durations: []time.Duration{3 * time.Hour, 4 * time.Hour, 3 * time.Hour},
+		},
+		{
+			name:      "cut at the ends",
+			from:      time.Date(2024, time.March, 30, 10, 0, 0, 0, rome),
+			to:        time.Date(2024, time.March, 31, 3, 30, 0, 0, rome),
+			durations: []time.Duration{2 * time.Hour, 90 * time.Minute},
+		},
+		{
+			name: "empty range",
+			from: time.Date(2024, time.March, 31, 0, 0, 0, 0, rome),
+			to:   time.Date(2024, time.March, 31, 0, 0, 0, 0, rome),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			intervals, err := Expand(tt, c.from, c.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(intervals) != len(c.durations) {
+				t.Fatalf("Expand() returned %d intervals, want %d: %v", len(intervals), len(c.durations), intervals)
+			}
+
+			for i, interval := range intervals {
+				if got := interval.End.Sub(interval.Start); got != c.durations[i] {
+					t.Errorf("interval %d lasts %s, want %s", i, got, c.durations[i])
+				}
+
+				if interval.Start.Location().String() != "Europe/Rome" {
+					t.Errorf("interval %d is in %s", i, interval.Start.Location())
+				}
+			}
+		})
+	}
+}
+
+func TestDayExceptionsAndValidity(t *testing.T) {
+	until := time.Date(2024, time.December, 31, 0, 0, 0, 0, time.UTC)
+	tt := &types.Timetable{
+		ValidFrom:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		ValidUntil:    &until,
+		Timezone:      "Europe/Rome",
+		RotationWeeks: 1,
+		Monday: []types.TimetableDay{
+			{DayOfWeek: types.Monday, Opening: "09:00", Closing: "18:00", Kind: types.KindOpen},
+			{DayOfWeek: types.Monday, Opening: "13:00", Closing: "14:00", Kind: types.KindBreak},
+		},
+		Exceptions: []types.TimetableException{
+			{Date: "2024-04-01", Name: "Easter Monday", Closed: true},
+			{Date: "2024-04-08", Name: "Short day", Opening: "10:00", Closing: "12:00"},
+		},
+	}
+
+	cases := []struct {
+		name  string
+		day   time.Time
+		count int
+	}{
+		{name: "regular day with a break", day: time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC), count: 2},
+		{name: "closed exception", day: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), count: 0},
+		{name: "exception with times", day: time.Date(2024, time.April, 8, 0, 0, 0, 0, time.UTC), count: 1},
+		{name: "closed weekday", day: time.Date(2024, time.March, 26, 0, 0, 0, 0, time.UTC), count: 0},
+		{name: "before validity", day: time.Date(2023, time.December, 25, 0, 0, 0, 0, time.UTC), count: 0},
+		{name: "after validity", day: time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC), count: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			intervals, err := Day(tt, c.day.Year(), c.day.Month(), c.day.Day())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(intervals) != c.count {
+				t.Errorf("Day() returned %d intervals, want %d: %v", len(intervals), c.count, intervals)
+			}
+		})
+	}
+}
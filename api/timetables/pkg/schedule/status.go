@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/asimpleidea/appoint/api/timetables/pkg/interval"
+	"github.com/asimpleidea/appoint/api/timetables/pkg/types"
+)
+
+const (
+	day time.Duration = 24 * time.Hour
+	// maxLookahead is how far in the future the next opening or closing is
+	// looked for.
+	maxLookahead time.Duration = 366 * day
+)
+
+// Status tells whether a timetable is open at an instant and when it is
+// going to open or close next.
+type Status struct {
+	At             time.Time  `json:"at" yaml:"at"`
+	Open           bool       `json:"open" yaml:"open"`
+	NextTransition *time.Time `json:"next_transition,omitempty" yaml:"nextTransition,omitempty"`
+	NextOpening    *time.Time `json:"next_opening,omitempty" yaml:"nextOpening,omitempty"`
+	NextClosing    *time.Time `json:"next_closing,omitempty" yaml:"nextClosing,omitempty"`
+}
+
+// StatusAt returns the status of the timetable at the provided instant.
+// Adjacent intervals are considered as one, so that closing at 14:00 and
+// opening again at 14:00 is not a transition. Transitions more than a year
+// away are not reported. The timetable must have been loaded in full.
+func StatusAt(tt *types.Timetable, at time.Time) (*Status, error) {
+	loc, err := Location(tt)
+	if err != nil {
+		return nil, err
+	}
+
+	at = at.In(loc)
+	status := &Status{At: at}
+
+	// Try with a week first, as it is usually enough.
+	for _, lookahead := range []time.Duration{7 * day, maxLookahead} {
+		end := at.Add(lookahead)
+
+		intervals, err := Expand(tt, at.Add(-day), end)
+		if err != nil {
+			return nil, err
+		}
+
+		status.Open, status.NextOpening, status.NextClosing = false, nil, nil
+		if complete := fillStatus(status, interval.Normalize(intervals), end); complete {
+			break
+		}
+	}
+
+	status.NextTransition = status.NextOpening
+	if status.Open {
+		status.NextTransition = status.NextClosing
+	}
+
+	return status, nil
+}
+
+// fillStatus fills the status from the intervals up to end and tells
+// whether both the next opening and the next closing were found.
+func fillStatus(status *Status, intervals []Interval, end time.Time) bool {
+	for i := 0; i < len(intervals); i++ {
+		interval := intervals[i]
+		if !interval.End.After(status.At) {
+			continue
+		}
+
+		// An interval that ends with the lookahead may actually go on.
+		var closing *time.Time
+		if interval.End.Before(end) {
+			closing = &interval.End
+		}
+
+		if interval.Start.After(status.At) {
+			status.NextOpening = &interval.Start
+			status.NextClosing = closing
+			return true
+		}
+
+		status.Open = true
+		status.NextClosing = closing
+		if i+1 < len(intervals) {
+			status.NextOpening = &intervals[i+1].Start
+		}
+
+		return closing != nil && status.NextOpening != nil
+	}
+
+	return false
+}
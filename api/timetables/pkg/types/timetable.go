@@ -14,30 +14,196 @@ const (
 	Sunday    DOW = "sunday"
 )
 
+// OwnerType is the kind of entity whose hours a timetable describes.
+type OwnerType string
+
+const (
+	OwnerLocation OwnerType = "location"
+	OwnerStaff    OwnerType = "staff"
+	OwnerResource OwnerType = "resource"
+	OwnerService  OwnerType = "service"
+)
+
+// Owner is the entity whose hours a timetable describes. When an owner has
+// more timetables valid on the same day, the one with the highest priority
+// is in effect.
+type Owner struct {
+	Type     OwnerType `json:"type" yaml:"type"`
+	ID       uint      `json:"id" yaml:"id"`
+	Priority int       `json:"priority" yaml:"priority"`
+}
+
+// IntervalKind tells what an interval of a day is for.
+type IntervalKind string
+
+const (
+	// KindOpen is an interval when the place is open. Intervals without a
+	// kind are open.
+	KindOpen IntervalKind = "open"
+	// KindBreak is an interval when the place is closed, e.g. for lunch. It
+	// must be inside an open interval.
+	KindBreak IntervalKind = "break"
+	// KindReception is an interval when only the reception is open.
+	KindReception IntervalKind = "reception-only"
+	// KindPhone is an interval when the place can only be reached by phone.
+	KindPhone IntervalKind = "phone-only"
+)
+
 type Timetable struct {
-	ID         uint           `json:"id" yaml:"id"`
-	CreatedAt  time.Time      `json:"created_at" yaml:"createdAt"`
-	UpdatedAt  time.Time      `json:"updated_at" yaml:"updatedAt"`
-	DeletedAt  *time.Time     `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
-	Name       string         `json:"name" yaml:"name"`
-	ValidFrom  time.Time      `json:"valid_from" yaml:"validFrom"`
-	ValidUntil *time.Time     `json:"valid_until" yaml:"validUntil"`
-	Monday     []TimetableDay `json:"monday,omitempty" yaml:"monday,omitempty"`
-	Tuesday    []TimetableDay `json:"tuesday,omitempty" yaml:"tuesday,omitempty"`
-	Wednesday  []TimetableDay `json:"wednesday,omitempty" yaml:"wednesday,omitempty"`
-	Thursday   []TimetableDay `json:"thursday,omitempty" yaml:"thursday,omitempty"`
-	Friday     []TimetableDay `json:"friday,omitempty" yaml:"friday,omitempty"`
-	Saturday   []TimetableDay `json:"saturday,omitempty" yaml:"saturday,omitempty"`
-	Sunday     []TimetableDay `json:"sunday,omitempty" yaml:"sunday,omitempty"`
+	ID            uint                 `json:"id" yaml:"id"`
+	CreatedAt     time.Time            `json:"created_at" yaml:"createdAt"`
+	UpdatedAt     time.Time            `json:"updated_at" yaml:"updatedAt"`
+	DeletedAt     *time.Time           `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
+	Name          string               `json:"name" yaml:"name"`
+	ValidFrom     time.Time            `json:"valid_from" yaml:"validFrom"`
+	ValidUntil    *time.Time           `json:"valid_until" yaml:"validUntil"`
+	Timezone      string               `json:"timezone" yaml:"timezone"`
+	RotationWeeks int                  `json:"rotation_weeks" yaml:"rotationWeeks"`
+	RotationStart *time.Time           `json:"rotation_start,omitempty" yaml:"rotationStart,omitempty"`
+	Owner         *Owner               `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Monday        []TimetableDay       `json:"monday,omitempty" yaml:"monday,omitempty"`
+	Tuesday       []TimetableDay       `json:"tuesday,omitempty" yaml:"tuesday,omitempty"`
+	Wednesday     []TimetableDay       `json:"wednesday,omitempty" yaml:"wednesday,omitempty"`
+	Thursday      []TimetableDay       `json:"thursday,omitempty" yaml:"thursday,omitempty"`
+	Friday        []TimetableDay       `json:"friday,omitempty" yaml:"friday,omitempty"`
+	Saturday      []TimetableDay       `json:"saturday,omitempty" yaml:"saturday,omitempty"`
+	Sunday        []TimetableDay       `json:"sunday,omitempty" yaml:"sunday,omitempty"`
+	Exceptions    []TimetableException `json:"exceptions,omitempty" yaml:"exceptions,omitempty"`
 }
 
 type TimetableDay struct {
+	ID          uint         `json:"id" yaml:"id"`
+	CreatedAt   time.Time    `json:"created_at" yaml:"createdAt"`
+	UpdatedAt   time.Time    `json:"updated_at" yaml:"updatedAt"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
+	TimeTableID uint         `json:"timetable_id" yaml:"timetableId"`
+	DayOfWeek   DOW          `json:"day_of_week" yaml:"dayOfWeek"`
+	Week        int          `json:"week" yaml:"week"`
+	Opening     string       `json:"opening" yaml:"opening"`
+	Closing     string       `json:"closing" yaml:"closing"`
+	Kind        IntervalKind `json:"kind" yaml:"kind"`
+	Label       string       `json:"label,omitempty" yaml:"label,omitempty"`
+}
+
+// OpeningHours is the week of a timetable in the OpenStreetMap opening_hours
+// syntax.
+type OpeningHours struct {
+	OpeningHours string `json:"opening_hours" yaml:"openingHours"`
+}
+
+// TimetableException overrides the week of a timetable on a specific date:
+// either the whole day is closed or its intervals replace the ones of the
+// week.
+type TimetableException struct {
 	ID          uint       `json:"id" yaml:"id"`
 	CreatedAt   time.Time  `json:"created_at" yaml:"createdAt"`
 	UpdatedAt   time.Time  `json:"updated_at" yaml:"updatedAt"`
 	DeletedAt   *time.Time `json:"deleted_at,omitempty" yaml:"deletedAt,omitempty"`
 	TimeTableID uint       `json:"timetable_id" yaml:"timetableId"`
-	DayOfWeek   DOW        `json:"day_of_week" yaml:"dayOfWeek"`
-	Opening     string     `json:"opening" yaml:"opening"`
-	Closing     string     `json:"closing" yaml:"closing"`
+	Date        string     `json:"date" yaml:"date"`
+	Name        string     `json:"name" yaml:"name"`
+	Closed      bool       `json:"closed" yaml:"closed"`
+	Opening     string     `json:"opening,omitempty" yaml:"opening,omitempty"`
+	Closing     string     `json:"closing,omitempty" yaml:"closing,omitempty"`
+	Source      string     `json:"source,omitempty" yaml:"source,omitempty"`
+}
+
+type ImportAction string
+
+const (
+	// ImportCreate means that the closure is created.
+	ImportCreate ImportAction = "create"
+	// ImportDuplicate means that the closure was already imported from the
+	// same source, so it is skipped.
+	ImportDuplicate ImportAction = "duplicate"
+	// ImportConflict means that the day already has other exceptions, which
+	// are kept.
+	ImportConflict ImportAction = "conflict"
+)
+
+// ImportedClosure is the outcome of importing a closure on a timetable.
+type ImportedClosure struct {
+	TimeTableID uint         `json:"timetable_id" yaml:"timetableId"`
+	Date        string       `json:"date" yaml:"date"`
+	Name        string       `json:"name" yaml:"name"`
+	Source      string       `json:"source" yaml:"source"`
+	Action      ImportAction `json:"action" yaml:"action"`
+}
+
+type RevisionAction string
+
+const (
+	RevisionCreate          RevisionAction = "create"
+	RevisionClone           RevisionAction = "clone"
+	RevisionDelete          RevisionAction = "delete"
+	RevisionSetDay          RevisionAction = "set-day"
+	RevisionDeleteDay       RevisionAction = "delete-day"
+	RevisionSetWeek         RevisionAction = "set-week"
+	RevisionSetException    RevisionAction = "set-exception"
+	RevisionDeleteException RevisionAction = "delete-exception"
+	RevisionImportClosures  RevisionAction = "import-closures"
+	RevisionSetOwner        RevisionAction = "set-owner"
+	RevisionRollback        RevisionAction = "rollback"
+)
+
+// TimetableRevision is a change to a timetable, with what the timetable
+// looked like before and after it. Before is nil for new timetables and
+// After is nil for deleted ones.
+type TimetableRevision struct {
+	ID          uint           `json:"id" yaml:"id"`
+	CreatedAt   time.Time      `json:"created_at" yaml:"createdAt"`
+	TimeTableID uint           `json:"timetable_id" yaml:"timetableId"`
+	Action      RevisionAction `json:"action" yaml:"action"`
+	Actor       string         `json:"actor,omitempty" yaml:"actor,omitempty"`
+	Before      *Timetable     `json:"before" yaml:"before"`
+	After       *Timetable     `json:"after" yaml:"after"`
+}
+
+// WeekDay returns the intervals of the timetable for the provided day of the
+// week. It only returns data if the timetable was loaded in full.
+func (t *Timetable) WeekDay(dow DOW) []TimetableDay {
+	switch dow {
+	case Monday:
+		return t.Monday
+	case Tuesday:
+		return t.Tuesday
+	case Wednesday:
+		return t.Wednesday
+	case Thursday:
+		return t.Thursday
+	case Friday:
+		return t.Friday
+	case Saturday:
+		return t.Saturday
+	case Sunday:
+		return t.Sunday
+	default:
+		return nil
+	}
+}
+
+// FilterKind removes from the days of the timetable the intervals that are
+// not of the provided kind.
+func (t *Timetable) FilterKind(kind IntervalKind) {
+	for _, days := range []*[]TimetableDay{&t.Monday, &t.Tuesday, &t.Wednesday,
+		&t.Thursday, &t.Friday, &t.Saturday, &t.Sunday} {
+		*days = FilterKind(*days, kind)
+	}
+}
+
+// FilterKind returns the intervals of the provided kind.
+func FilterKind(days []TimetableDay, kind IntervalKind) []TimetableDay {
+	filtered := []TimetableDay{}
+	for _, day := range days {
+		if day.Kind == kind || (day.Kind == "" && kind == KindOpen) {
+			filtered = append(filtered, day)
+		}
+	}
+
+	return filtered
+}
+
+// DOWFromWeekday converts a time.Weekday to a DOW.
+func DOWFromWeekday(weekday time.Weekday) DOW {
+	return [7]DOW{Sunday, Monday, Tuesday, Wednesday, Thursday, Friday, Saturday}[weekday]
 }